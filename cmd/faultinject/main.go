@@ -0,0 +1,58 @@
+// faultinject corrupts simulated streams in various ways and reports
+// how the decoder copes with them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mrrtf/sampa/pkg/fault"
+)
+
+func main() {
+	var ntrials int
+	var seed int64
+	var kinds string
+	var verbose bool
+	flag.IntVar(&ntrials, "n", 1000, "number of trials per kind of fault")
+	flag.Int64Var(&seed, "seed", 1, "random seed")
+	flag.StringVar(&kinds, "kinds", "", "comma separated list of the kinds of faults to inject (default all)")
+	flag.BoolVar(&verbose, "v", false, "keep the decoder log messages")
+	flag.Parse()
+
+	if !verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+	list := fault.Kinds()
+	if kinds != "" {
+		list = nil
+		for _, s := range strings.Split(kinds, ",") {
+			k, ok := fault.ParseKind(strings.TrimSpace(s))
+			if !ok {
+				fmt.Fprintln(os.Stderr, "unknown kind of fault", s)
+				os.Exit(1)
+			}
+			list = append(list, k)
+		}
+	}
+	crashed := false
+	for _, k := range list {
+		res, err := fault.Run(k, ntrials, seed)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(res)
+		for _, p := range res.Panics {
+			fmt.Println("   ", p)
+			crashed = true
+		}
+	}
+	if crashed {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/rdh"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+// command is a subcommand of gbtdatedump. All the subcommands
+// share the same (input) options.
+type command struct {
+	name string
+	args string
+	help string
+}
+
+var commands = []command{
+	{"packets", "", "decode the SAMPA packets and print them (default)"},
+	{"events", "", "print the DATE event headers (or the CRU page headers of rdh inputs)"},
+	{"gbt", "", "hex dump the GBT words, annotated with the 2-bit slice of each elink"},
+	{"elink", "N", "print the raw bitstream of elink N"},
+	{"stats", "", "decode the SAMPA packets and print the summary tables"},
+}
+
+// nBitsPerLine is the number of bits per line of the elink bitstream dumps
+const nBitsPerLine = sampa.HeaderSize
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [options] file\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name+" "+c.args, c.help)
+	}
+	fmt.Fprintf(os.Stderr, "\nOptions:\n")
+	flag.PrintDefaults()
+}
+
+// parseCommand gets the subcommand (and the elink of the elink command)
+// from the command line arguments, and parses the options that follow
+func parseCommand(args []string) (string, int) {
+	name := "packets"
+	elink := -1
+	if len(args) > 0 {
+		for _, c := range commands {
+			if args[0] == c.name {
+				name = c.name
+				args = args[1:]
+				break
+			}
+		}
+	}
+	if name == "elink" {
+		var err error
+		if len(args) > 0 {
+			elink, err = strconv.Atoi(args[0])
+		}
+		if len(args) == 0 || err != nil || elink < 0 || elink >= sampa.NofELinks {
+			fmt.Fprintf(os.Stderr, "elink expects an elink number between 0 and %d\n", sampa.NofELinks-1)
+			flag.Usage()
+			os.Exit(2)
+		}
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	return name, elink
+}
+
+// dumpEvents prints the headers of the DATE events, or of the
+// CRU pages, of the input
+func dumpEvents(r input, dr *date.DateReader) {
+	if rr, ok := r.(*rdh.Reader); ok {
+		for flagMaxEvents == 0 || rr.NofPages() < flagMaxEvents {
+			err := rr.NextPage()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(rr.Header())
+		}
+		fmt.Printf("%d CRU pages\n", rr.NofPages())
+		return
+	}
+	if dr == nil {
+		log.Fatal("events requires a DATE or rdh input")
+	}
+	for flagMaxEvents == 0 || dr.NofEvents() < flagMaxEvents {
+		err := dr.GetNextEvent()
+		if err == io.EOF {
+			break
+		}
+		if err == date.ErrSkipped {
+			continue
+		}
+		if err != nil && !date.IsEndOfEvent(err) {
+			log.Fatal(err)
+		}
+		fmt.Println(dr.Header())
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	fmt.Printf("%d events\n", dr.NofEvents())
+}
+
+// elinkBits returns the two bits of the elink in the GBT word,
+// in time order (same bit order as sampa.Dispatch)
+func elinkBits(ten []byte, elink int) (byte, byte) {
+	b := ten[elink/4]
+	k := uint(elink%4) * 2
+	return (b >> (k + 1)) & 1, (b >> k) & 1
+}
+
+// gbtDumper returns the function printing a GBT word in hexadecimal
+// (most significant byte first), followed by the 2-bit slices of the
+// elinks, grouped by 4 (elinks 0-3, 4-7...), masked elinks
+// being shown as '..'
+func gbtDumper(r input) func(ten []byte) {
+	_, multiLink := r.(linkReader)
+	fmt.Println("    word GBT word             | elinks 0-3, 4-7, ..., 36-39")
+	var buf bytes.Buffer
+	return func(ten []byte) {
+		buf.Reset()
+		if multiLink {
+			fmt.Fprintf(&buf, "link %4d ", r.(linkReader).Link())
+		}
+		fmt.Fprintf(&buf, "%8d ", r.NofGBTwords()-1)
+		for i := len(ten) - 1; i >= 0; i-- {
+			fmt.Fprintf(&buf, "%02X", ten[i])
+		}
+		buf.WriteString(" |")
+		for e := 0; e < sampa.NofELinks; e++ {
+			if e%4 == 0 {
+				buf.WriteByte(' ')
+			}
+			if flagMaskELink&(uint64(1)<<uint(e)) != 0 {
+				buf.WriteString("..")
+				continue
+			}
+			b1, b0 := elinkBits(ten, e)
+			buf.WriteByte('0' + b1)
+			buf.WriteByte('0' + b0)
+		}
+		fmt.Println(buf.String())
+	}
+}
+
+// elinkDumper returns the function accumulating the bits of the elink
+// in each GBT word, printed by lines of nBitsPerLine bits in time order
+// prefixed by the index of their first bit, and the function printing
+// the last incomplete lines
+func elinkDumper(r input, elink int) (func(ten []byte), func()) {
+	lr, multiLink := r.(linkReader)
+	bits := make(map[int][]byte) // per link
+	nbits := make(map[int]int)
+	printLine := func(link int) {
+		if multiLink {
+			fmt.Printf("link %4d ", link)
+		}
+		fmt.Printf("%10d %s\n", nbits[link]-len(bits[link]), bits[link])
+		bits[link] = bits[link][:0]
+	}
+	dump := func(ten []byte) {
+		link := 0
+		if multiLink {
+			link = lr.Link()
+		}
+		b1, b0 := elinkBits(ten, elink)
+		bits[link] = append(bits[link], '0'+b1, '0'+b0)
+		nbits[link] += 2
+		if len(bits[link]) >= nBitsPerLine {
+			printLine(link)
+		}
+	}
+	flush := func() {
+		var links []int
+		for link, b := range bits {
+			if len(b) > 0 {
+				links = append(links, link)
+			}
+		}
+		sort.Ints(links)
+		for _, link := range links {
+			printLine(link)
+		}
+	}
+	return dump, flush
+}
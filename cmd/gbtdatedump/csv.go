@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagCSV string
+var flagCSVSamples bool
+var flagCSVColumns string
+
+// csvCell is what a column shows of a cluster, or of one
+// of its samples (i being the index of the sample)
+type csvCell func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string
+
+var csvColumns = map[string]csvCell{
+	"event": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string { return event },
+	"elink": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string { return strconv.Itoa(p.ELink()) },
+	"hadd": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string {
+		return strconv.Itoa(int(p.Header().Hadd()))
+	},
+	"chadd": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string {
+		return strconv.Itoa(int(p.Header().CHadd()))
+	},
+	"bx": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string {
+		return strconv.Itoa(int(p.Header().BXcount()))
+	},
+	"ts": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string {
+		return strconv.Itoa(c.Timestamp())
+	},
+	"nsamples": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string {
+		return strconv.Itoa(len(c.Samples()))
+	},
+	"samples": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string {
+		s := make([]string, len(c.Samples()))
+		for i, v := range c.Samples() {
+			s[i] = strconv.Itoa(v)
+		}
+		return strings.Join(s, " ")
+	},
+	"sample": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string { return strconv.Itoa(i) },
+	"adc": func(event string, p *sampa.Packet, c *sampa.Cluster, i int) string {
+		return strconv.Itoa(c.Samples()[i])
+	},
+}
+
+// the default columns, per cluster and per sample
+const csvClusterColumns = "event,elink,hadd,chadd,bx,ts,nsamples,samples"
+const csvSampleColumns = "event,elink,hadd,chadd,bx,ts,sample,adc"
+
+func init() {
+	flag.StringVar(&flagCSV, "csv", "", "instead of printing the packets, write one CSV row per cluster into `file` (- for the standard output)")
+	flag.BoolVar(&flagCSVSamples, "csv-samples", false, "write one CSV row per sample instead of per cluster")
+	flag.StringVar(&flagCSVColumns, "csv-columns", "", "comma separated list of the CSV columns, among "+
+		"event, elink, hadd, chadd, bx, ts, nsamples, samples, and sample, adc with -csv-samples (default "+csvClusterColumns+
+		" or "+csvSampleColumns+" with -csv-samples)")
+}
+
+// parseCSVColumns returns the names and the cells of the comma separated
+// list of columns, or of the default columns if the list is empty.
+// The sample and adc columns are only valid with one row per sample.
+func parseCSVColumns(columns string, samples bool) ([]string, []csvCell, error) {
+	if columns == "" {
+		columns = csvClusterColumns
+		if samples {
+			columns = csvSampleColumns
+		}
+	}
+	names := strings.Split(columns, ",")
+	var cells []csvCell
+	for _, name := range names {
+		cell, ok := csvColumns[name]
+		if !ok || (!samples && (name == "sample" || name == "adc")) {
+			return nil, nil, errors.New(fmt.Sprintf("invalid CSV column %q", name))
+		}
+		cells = append(cells, cell)
+	}
+	return names, cells, nil
+}
+
+// setupCSV makes the clusters be written as CSV. It returns
+// the function to be called once all the packets are written.
+func setupCSV(dr *date.DateReader) func() {
+	names, cells, err := parseCSVColumns(flagCSVColumns, flagCSVSamples)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var f *os.File
+	if flagCSV == "-" {
+		f = os.Stdout
+	} else {
+		f, err = os.Create(flagCSV)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	w := csv.NewWriter(f)
+	w.Write(names)
+	row := make([]string, len(cells))
+	write := func(event string, p *sampa.Packet, c *sampa.Cluster, i int) {
+		for j, cell := range cells {
+			row[j] = cell(event, p, c, i)
+		}
+		if err := w.Write(row); err != nil {
+			log.Fatal(err)
+		}
+	}
+	addSink(func(p *sampa.Packet, _ origin) {
+		event := currentEventID(dr)
+		clusters := p.Clusters()
+		for k := range clusters {
+			c := &clusters[k]
+			if !flagCSVSamples {
+				write(event, p, c, -1)
+				continue
+			}
+			for i := range c.Samples() {
+				write(event, p, c, i)
+			}
+		}
+	})
+	return func() {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Fatal(err)
+		}
+		if flagCSV != "-" {
+			if err := f.Close(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func TestParseCSVColumns(t *testing.T) {
+	names, cells, err := parseCSVColumns("", false)
+	if err != nil || strings.Join(names, ",") != csvClusterColumns || len(cells) != len(names) {
+		t.Errorf("Expected the default cluster columns got %v %v", names, err)
+	}
+	names, _, err = parseCSVColumns("", true)
+	if err != nil || strings.Join(names, ",") != csvSampleColumns {
+		t.Errorf("Expected the default sample columns got %v %v", names, err)
+	}
+	for _, columns := range []string{"elink,foo", "elink,adc", "sample", "elink,,hadd"} {
+		if _, _, err := parseCSVColumns(columns, false); err == nil {
+			t.Errorf("Expected an error for the cluster columns %q", columns)
+		}
+	}
+	if _, _, err := parseCSVColumns("elink,sample,adc", true); err != nil {
+		t.Errorf("Expected valid sample columns got %v", err)
+	}
+}
+
+func TestCSVCells(t *testing.T) {
+	p, err := sampa.NewDataPacket(3, 1, 7, 1234, []sampa.Cluster{sampa.NewCluster(12, []int{5, 60, 7})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cells, err := parseCSVColumns("event,elink,hadd,chadd,bx,ts,nsamples,samples,sample,adc", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &p.Clusters()[0]
+	var row []string
+	for _, cell := range cells {
+		row = append(row, cell("1:2:3", p, c, 1))
+	}
+	expected := "1:2:3,3,1,7,1234,12,3,5 60 7,1,60"
+	if strings.Join(row, ",") != expected {
+		t.Errorf("Expected %q got %q", expected, strings.Join(row, ","))
+	}
+}
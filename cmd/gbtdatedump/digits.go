@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+// version is the version of the decoder recorded in the digit files,
+// to be set with -ldflags "-X main.version=..."
+var version = "dev"
+
+var flagDigits string
+var flagRun uint64
+
+func init() {
+	flag.StringVar(&flagDigits, "digits", "", "instead of printing the packets, write their clusters into `file` in the binary digit format")
+	flag.Uint64Var(&flagRun, "run", 0, "run number to record in the digit file (default the one of the DATE events)")
+}
+
+// setupDigits makes the clusters be written as digits, each with the
+// bunch crossing counter of its packet and the timestamp of its
+// cluster (see sampa.Digit). The file is created with the first packet,
+// to record the run number of the DATE input. It returns the function
+// to be called once all the packets are written.
+func setupDigits(dr *date.DateReader) func() {
+	var f *os.File
+	var w *sampa.DigitWriter
+	create := func() {
+		h := sampa.DigitHeader{Run: flagRun, DecoderVersion: version, Options: make(map[string]string)}
+		if h.Run == 0 && dr != nil {
+			h.Run = uint64(dr.Header().RunNumber)
+		}
+		flag.Visit(func(f *flag.Flag) {
+			h.Options[f.Name] = f.Value.String()
+		})
+		var err error
+		if f, err = os.Create(flagDigits); err != nil {
+			log.Fatal(err)
+		}
+		if w, err = sampa.NewDigitWriter(f, h); err != nil {
+			log.Fatal(err)
+		}
+	}
+	addSink(func(p *sampa.Packet, _ origin) {
+		if w == nil {
+			create()
+		}
+		if err := w.WritePacket(p); err != nil {
+			log.Fatal(err)
+		}
+	})
+	return func() {
+		if w == nil {
+			create()
+		}
+		log.Println(w.NofDigits(), "digits written into", flagDigits)
+		if err := w.Flush(); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
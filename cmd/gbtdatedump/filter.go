@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrrtf/sampa/pkg/date"
+)
+
+var flagEventTypes string
+var flagTriggerClasses string
+var flagDetectors string
+var flagFirstID string
+var flagLastID string
+var flagFrom string
+var flagTo string
+
+func init() {
+	flag.StringVar(&flagEventTypes, "types", "", "comma separated list of DATE event types to decode, e.g. PHYSICS,CALIBRATION (default all)")
+	flag.StringVar(&flagTriggerClasses, "trigger-classes", "", "comma separated list of trigger classes (0-99) : only events with one of those are decoded (default all)")
+	flag.StringVar(&flagDetectors, "detectors", "", "comma separated list of detector ids (0-30) : only events with one of those are decoded (default all)")
+	flag.StringVar(&flagFirstID, "first-id", "", "first event id (period:orbit:bc) to decode")
+	flag.StringVar(&flagLastID, "last-id", "", "last event id (period:orbit:bc) to decode")
+	flag.StringVar(&flagFrom, "from", "", "decode only the events recorded at or after this time (e.g. 2017-04-14 11:25:00, local time)")
+	flag.StringVar(&flagTo, "to", "", "decode only the events recorded before this time (e.g. 2017-04-14 11:30:00, local time)")
+}
+
+func splitList(s string) []string {
+	var v []string
+	for _, x := range strings.Split(s, ",") {
+		x = strings.TrimSpace(x)
+		if x != "" {
+			v = append(v, x)
+		}
+	}
+	return v
+}
+
+// buildFilter converts the command line options into a DATE event filter
+func buildFilter() (date.EventFilter, error) {
+	var f date.EventFilter
+	for _, name := range splitList(flagEventTypes) {
+		k, err := date.ParseEventKind(name)
+		if err != nil {
+			return f, err
+		}
+		f.Types = append(f.Types, k)
+	}
+	for _, s := range splitList(flagTriggerClasses) {
+		i, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return f, err
+		}
+		if err := f.SetTriggerClass(uint(i)); err != nil {
+			return f, err
+		}
+	}
+	for _, s := range splitList(flagDetectors) {
+		i, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return f, err
+		}
+		if err := f.SetDetector(uint(i)); err != nil {
+			return f, err
+		}
+	}
+	var err error
+	if flagFirstID != "" {
+		if f.FirstID, err = date.ParseEventID(flagFirstID); err != nil {
+			return f, err
+		}
+	}
+	if flagLastID != "" {
+		if f.LastID, err = date.ParseEventID(flagLastID); err != nil {
+			return f, err
+		}
+	}
+	if flagFrom != "" {
+		if f.From, err = parseTime(flagFrom); err != nil {
+			return f, err
+		}
+	}
+	if flagTo != "" {
+		if f.To, err = parseTime(flagTo); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02T15:04:05.999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+}
+
+// parseTime decodes a time given either as a number of seconds
+// since the Unix epoch, as RFC3339 or as a local time in one of the
+// timeLayouts formats
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, l := range timeLayouts {
+		if t, err := time.ParseInLocation(l, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("cannot understand time %s", s))
+}
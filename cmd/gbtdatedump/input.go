@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/gbt"
+	"github.com/mrrtf/sampa/pkg/rdh"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagFormat string
+var flagGBTOffset int
+
+func init() {
+	flag.StringVar(&flagFormat, "format", "auto", "format of the input file : date, rdh (CRU pages), gbt10 or gbt16 (raw GBT words stored in 10 or 16 bytes), or auto to detect it")
+	flag.IntVar(&flagGBTOffset, "gbt-offset", 0, "position of the GBT word within each stored word (raw GBT formats only)")
+}
+
+// input is the source of GBT words to be decoded
+type input interface {
+	gbt.WordReader
+	io.Closer
+}
+
+// linkReader is an input mixing the GBT words of several links
+type linkReader interface {
+	Link() int
+}
+
+// linkElinks holds the elinks of each GBT link of a multi-link input
+var linkElinks = make(map[int][]sampa.ELink)
+
+// elinksOf returns the elinks the current GBT word of r is to be
+// dispatched to
+func elinksOf(r input) []sampa.ELink {
+	lr, ok := r.(linkReader)
+	if !ok {
+		return elinks
+	}
+	return elinksOfLink(lr.Link())
+}
+
+// elinksOfLink returns the elinks of the given link,
+// creating them on first use
+func elinksOfLink(link int) []sampa.ELink {
+	e, ok := linkElinks[link]
+	if !ok {
+		for i := 0; i < 40; i++ {
+			e = append(e, sampa.NewELink(i))
+		}
+		linkElinks[link] = e
+		checkHeaders(e)
+		traceELinks(e, link)
+	}
+	return e
+}
+
+// checkHeaders enables the header check of the elinks if requested
+func checkHeaders(e []sampa.ELink) {
+	if !flagCheckHeaders {
+		return
+	}
+	for _, l := range e {
+		if c, ok := l.(sampa.Decoder); ok {
+			c.CheckHeaders(true)
+		}
+	}
+}
+
+// resetELinks makes all the elinks look for a sync again
+func resetELinks() {
+	all := [][]sampa.ELink{elinks}
+	for _, e := range linkElinks {
+		all = append(all, e)
+	}
+	for _, e := range all {
+		for _, l := range e {
+			if d, ok := l.(sampa.Decoder); ok {
+				d.Reset()
+			}
+		}
+	}
+}
+
+// currentEventID returns the id (period:orbit:bc) of the DATE
+// event being decoded, or an empty string for other inputs
+func currentEventID(dr *date.DateReader) string {
+	if dr == nil {
+		return ""
+	}
+	id := dr.Header().EventID
+	return fmt.Sprintf("%d:%d:%d", id.Period(), id.Orbit(), id.BunchCrossing())
+}
+
+// reportWriter returns where the reports (end of run summary,
+// statistics, ...) are to be written : the standard output, or the
+// standard error if a data output (-ndjson, -csv) uses the standard
+// output, which at most one of them can do
+func reportWriter() (io.Writer, error) {
+	n := 0
+	for _, name := range []string{flagNDJSON, flagCSV} {
+		if name == "-" {
+			n++
+		}
+	}
+	switch n {
+	case 0:
+		return os.Stdout, nil
+	case 1:
+		return os.Stderr, nil
+	}
+	return nil, errors.New("only one of -ndjson and -csv can write to the standard output")
+}
+
+// detectFormat guesses the format of the input file from its first bytes
+func detectFormat(inputFileName string) (string, error) {
+	f, err := os.Open(inputFileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	// large enough to get past the idle words at the start of raw GBT files
+	b := make([]byte, 16*4096)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	b = b[:n]
+	if date.HasMagic(b) {
+		return "date", nil
+	}
+	if rdh.IsRDH(b) {
+		return "rdh", nil
+	}
+	if gbt.DetectWordSize(b, flagGBTOffset) == 16 {
+		return "gbt16", nil
+	}
+	return "gbt10", nil
+}
+
+// openInput opens the input file according to its format.
+// The returned DateReader is nil if the input is not a DATE file.
+func openInput(inputFileName string) (input, *date.DateReader) {
+	format := flagFormat
+	if format == "auto" {
+		var err error
+		format, err = detectFormat(inputFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Input format detected as", format)
+	}
+	switch format {
+	case "date":
+		r := openDate(inputFileName)
+		return r, r
+	case "rdh":
+		r, err := rdh.Open(inputFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return r, nil
+	case "gbt10", "gbt16":
+		wordSize := 10
+		if format == "gbt16" {
+			wordSize = 16
+		}
+		r, err := gbt.Open(inputFileName, wordSize, flagGBTOffset)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return r, nil
+	}
+	log.Fatal(errors.New("unknown input format " + format))
+	return nil, nil
+}
+
+// openDate opens the DATE input file and configures the reader
+// according to the command line options
+func openDate(inputFileName string) *date.DateReader {
+	var r *date.DateReader
+	if flagMmap {
+		r = date.NewMmapReader(inputFileName)
+	} else {
+		r = date.NewReader(inputFileName)
+	}
+	if r == nil {
+		log.Fatal("cannot read file", inputFileName)
+	}
+	filter, err := buildFilter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.SetFilter(filter)
+	if flagNoEOP && flagRequireEOP {
+		log.Fatal("-no-eop and -require-eop are incompatible")
+	}
+	r.ExpectEOP(!flagNoEOP)
+	r.RequireEOP(flagRequireEOP)
+	setLayout(r, inputFileName)
+	if flagSaveIndex || flagSkip > 0 || flagEvent != "" || !filter.From.IsZero() {
+		seek(r, inputFileName, filter)
+	}
+	r.Prefetch(flagPrefetch)
+	return r
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagLayout string
+
+const nGBTwordsForLayoutDetection = 20000
+
+func init() {
+	flag.StringVar(&flagLayout, "layout", "default", "layout of the GBT words in the DATE payload (default, legacy or auto to detect it)")
+}
+
+// setLayout configures the reader with the GBT word layout
+// given on the command line
+func setLayout(r *date.DateReader, inputFileName string) {
+	var l date.Layout
+	var err error
+	if flagLayout == "auto" {
+		l, err = detectLayout(inputFileName)
+	} else {
+		l, err = date.LayoutByName(flagLayout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Using GBT word layout", l)
+	r.SetLayout(l)
+}
+
+// detectLayout decodes the first GBT words of the file with each
+// of the known layouts, and returns the one yielding the
+// most sync patterns on the elinks
+func detectLayout(inputFileName string) (date.Layout, error) {
+	best := date.DefaultLayout
+	bestCount := -1
+	for _, l := range date.Layouts() {
+		n, err := countSyncs(inputFileName, l)
+		if err != nil {
+			return best, err
+		}
+		log.Printf("layout %s : %d sync patterns in the first %d GBT words", l.Name, n, nGBTwordsForLayoutDetection)
+		if n > bestCount {
+			best = l
+			bestCount = n
+		}
+	}
+	return best, nil
+}
+
+// countSyncs returns the number of sync patterns found on all the elinks
+// of the first GBT words of the file, assuming the given layout
+func countSyncs(inputFileName string, l date.Layout) (int, error) {
+	r := date.NewReader(inputFileName)
+	if r == nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	defer r.Close()
+	r.SetLayout(l)
+	sync := sampa.SyncPattern.Uint64(0, -1)
+	var last [40]uint64 // last 50 bits of each elink, oldest bit at bit 0
+	n := 0
+	ten := make([]byte, 10)
+	for r.NofGBTwords() < nGBTwordsForLayoutDetection {
+		_, err := r.Read(ten)
+		if err == io.EOF {
+			break
+		}
+		if date.IsEndOfEvent(err) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		for i, b := range ten {
+			for j := uint(0); j < 8; j += 2 {
+				e := i*4 + int(j/2)
+				// same bit order as sampa.Dispatch
+				for _, bit := range []uint{j + 1, j} {
+					last[e] = last[e] >> 1
+					if b&(1<<bit) != 0 {
+						last[e] |= uint64(1) << uint(sampa.HeaderSize-1)
+					}
+					if last[e] == sync {
+						n++
+					}
+				}
+			}
+		}
+	}
+	return n, nil
+}
@@ -10,8 +10,8 @@ import (
 	"runtime/pprof"
 
 	"github.com/fatih/color"
-	"github.com/mrrtf/sampa/pkg/bitset"
 	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/rdh"
 	"github.com/mrrtf/sampa/pkg/sampa"
 )
 
@@ -22,9 +22,36 @@ var flagMemProfile string
 var flagMaxEvents int
 var flagNoDispatch bool
 var flagMaskELink uint64
+var flagRunRecords bool
+var flagSkip int
+var flagEvent string
+var flagSaveIndex bool
+var flagRequireEOP bool
+var flagNoEOP bool
+var flagPrefetch int
+var flagMmap bool
+var flagCheckHeaders bool
 var NumberOfProcessedEvents int = 0
 var elinks []sampa.ELink
-var gbt *bitset.BitSet
+
+// origin tells where a decoded packet was found in the input
+type origin struct {
+	word int // index of the GBT (or user logic) word completing the packet
+	link int // link of the packet, or -1 for single link inputs
+}
+
+// handlePacket is what is done with each decoded packet
+var handlePacket = printPacket
+
+// sinks are the outputs (-ndjson, -csv, -occupancy...) the decoded
+// packets go to instead of being printed
+var sinks []func(*sampa.Packet, origin)
+
+// addSink adds an output for the decoded packets
+func addSink(sink func(*sampa.Packet, origin)) {
+	sinks = append(sinks, sink)
+}
+
 var inData bool
 var nextCheckPoint int
 
@@ -33,7 +60,6 @@ func init() {
 		elinks = append(elinks, sampa.NewELink(i))
 	}
 	log.Println(len(elinks), "elinks created")
-	gbt = bitset.New(80)
 	inData = false
 	nextCheckPoint = 0
 	flag.IntVar(&flagMaxGBTwords, "nw", 0, "max number of GBT words to read")
@@ -43,15 +69,25 @@ func init() {
 	flag.StringVar(&flagMemProfile, "memprofile", "", "write memory profile to this file")
 	flag.BoolVar(&flagNoDispatch, "no-dispatch", false, "Disable GBT to elink dispatching")
 	flag.Uint64Var(&flagMaskELink, "elink-mask", 0, "40 bits mask to describe which elinks to skip in decoding (default none)")
+	flag.BoolVar(&flagRunRecords, "run-records", false, "Print the headers of the start and end of run events")
+	flag.IntVar(&flagSkip, "skip", 0, "number of DATE events to skip before starting to decode")
+	flag.StringVar(&flagEvent, "event", "", "event id (period:orbit:bc) of the first DATE event to decode")
+	flag.IntVar(&flagPrefetch, "prefetch", 0, "number of DATE events to read in advance in the background (default none)")
+	flag.BoolVar(&flagMmap, "mmap", false, "Memory map the input file instead of reading it (Linux only)")
+	flag.BoolVar(&flagRequireEOP, "require-eop", false, "Skip the DATE events without a valid end of packet word")
+	flag.BoolVar(&flagNoEOP, "no-eop", false, "The DATE events do not end with an end of packet word : decode all their quartets as GBT words")
+	flag.BoolVar(&flagCheckHeaders, "check-headers", false, "Check the Hamming code and parity of the SAMPA headers, correcting single bit errors and dropping the other corrupted headers, and the parity of the payloads")
+	flag.BoolVar(&flagSaveIndex, "save-index", false, "Save the event index of the input file into a sidecar (.idx) file")
 	log.SetFlags(log.Llongfile)
 	// log.SetOutput(ioutil.Discard)
 }
 
 func main() {
 
-	fmt.Println(runtime.GOMAXPROCS(1))
+	log.Println("GOMAXPROCS was", runtime.GOMAXPROCS(1))
 
-	flag.Parse()
+	flag.Usage = usage
+	cmd, elink := parseCommand(os.Args[1:])
 	if flagCpuProfile != "" {
 		f, err := os.Create(flagCpuProfile)
 		if err != nil {
@@ -63,26 +99,146 @@ func main() {
 	if flagNoColor {
 		color.NoColor = true
 	}
+	checkHeaders(elinks)
 	if flag.NArg() == 0 {
 		flag.Usage()
 		return
 	}
+	out, err := reportWriter()
+	if err != nil {
+		log.Fatal(err)
+	}
 	inputFileName := flag.Args()[0]
-	r := date.NewReader(inputFileName)
+	log.Println("Reading from ", inputFileName)
+	r, dr := openInput(inputFileName)
+	defer r.Close()
+	switch cmd {
+	case "events":
+		dumpEvents(r, dr)
+		return
+	case "gbt":
+		defer report(out, r, dr)
+		forEachWord(out, r, dr, gbtDumper(r))
+		return
+	case "elink":
+		defer report(out, r, dr)
+		dump, flush := elinkDumper(r, elink)
+		forEachWord(out, r, dr, dump)
+		flush()
+		return
+	case "stats":
+		handlePacket = func(p *sampa.Packet, o origin) {}
+	}
+	if flagTrace != "" {
+		defer setupTrace()()
+	}
+	if flagNDJSON != "" {
+		defer setupNDJSON(dr)()
+	}
+	if flagDigits != "" {
+		defer setupDigits(dr)()
+	}
+	if flagCSV != "" {
+		defer setupCSV(dr)()
+	}
+	if flagPedestals != "" {
+		setupPedestals()
+		defer writePedestals()
+	}
+	if flagOccupancy != "" {
+		setupOccupancy()
+		defer writeOccupancy(out)
+	}
+	if flagTiming != "" {
+		setupTiming()
+		defer writeTiming(out)
+	}
+	if len(sinks) > 0 {
+		handlePacket = func(p *sampa.Packet, o origin) {
+			for _, sink := range sinks {
+				sink(p, o)
+			}
+		}
+	}
+	if flagStats != "" || cmd == "stats" {
+		setupStats()
+		defer writeStats(out)
+	}
+	if flagPedestalTable != "" {
+		z := setupZeroSuppression()
+		defer func() {
+			if z.NofUnknownChannels() > 0 {
+				fmt.Fprintf(out, "%d packets of channels without pedestal\n", z.NofUnknownChannels())
+			}
+		}()
+	}
+	if flagUserLogic {
+		rr, ok := r.(*rdh.Reader)
+		if !ok {
+			log.Fatal("-user-logic requires an input in rdh format")
+		}
+		decodeUserLogic(out, rr)
+		return
+	}
 	defer func() {
-		fmt.Printf("Happy ending. I've read %d events and %d GBT words\n",
-			r.NofEvents(), r.NofGBTwords())
+		if decodingErrors.Entries() > 0 {
+			fmt.Fprintf(out, "%d GBT words with decoding errors\n", decodingErrors.Entries())
+		}
+		report(out, r, dr)
 	}()
-	if r == nil {
-		log.Fatal("cannot read file", inputFileName)
+	forEachWord(out, r, dr, func(ten []byte) {
+		if flagNoDispatch {
+			return
+		}
+		o := origin{word: r.NofGBTwords() - 1, link: -1}
+		if lr, ok := r.(linkReader); ok {
+			o.link = lr.Link()
+		}
+		err := sampa.DispatchFunc(ten, elinksOf(r), flagMaskELink, func(p *sampa.Packet) {
+			handlePacket(p, o)
+		})
+		if err != nil {
+			// the elinks recover by themselves, just report
+			countDecodingError(err)
+			log.Println(err)
+		}
+	})
+}
+
+// report writes what has been read into w
+func report(w io.Writer, r input, dr *date.DateReader) {
+	if rr, ok := r.(*rdh.Reader); ok {
+		fmt.Fprintf(w, "Happy ending. I've read %d CRU pages of %d links and %d GBT words\n",
+			rr.NofPages(), len(linkElinks), rr.NofGBTwords())
+		for _, e := range []error{rdh.ErrPacketCounter, rdh.ErrPageCounter, rdh.ErrMissingStopBit, rdh.ErrUnalignedPayload} {
+			if rr.NofErrors(e) > 0 {
+				fmt.Fprintf(w, "%d times %v\n", rr.NofErrors(e), e)
+			}
+		}
+		return
 	}
-	log.Println("Reading from ", inputFileName)
+	if dr == nil {
+		fmt.Fprintf(w, "Happy ending. I've read %d GBT words\n", r.NofGBTwords())
+		return
+	}
+	fmt.Fprintf(w, "Happy ending. I've read %d events and %d GBT words\n",
+		dr.NofEvents(), dr.NofGBTwords())
+	if dr.NofMissingEOP() > 0 || dr.NofInvalidEOP() > 0 {
+		fmt.Fprintf(w, "%d events without EOP and %d events with an invalid EOP\n",
+			dr.NofMissingEOP(), dr.NofInvalidEOP())
+	}
+}
+
+// forEachWord calls handle with each GBT word (its 10 bytes) of the
+// input, within the limits given on the command line. The run records
+// are written into out if asked for.
+func forEachWord(out io.Writer, r input, dr *date.DateReader, handle func(ten []byte)) {
 	ten := make([]byte, 10)
 	for {
 		if flagMaxGBTwords > 0 && r.NofGBTwords() >= flagMaxGBTwords {
 			break
 		}
-		if flagMaxEvents > 0 && r.NofEvents() >= flagMaxEvents {
+		if flagMaxEvents > 0 && dr != nil && dr.NofEvents() >= flagMaxEvents {
 			break
 		}
 
@@ -93,8 +249,18 @@ func main() {
 			if err == io.EOF {
 				break
 			}
-			if err == date.ErrEndOfEvent {
+			if err == io.ErrUnexpectedEOF {
+				log.Println("input ends in the middle of an event")
+				break
+			}
+			if dr != nil && dr.IsDiscontinuity(err) {
+				resetELinks()
+			}
+			if date.IsEndOfEvent(err) {
 				// fmt.Println("end of event ", r.NofEvents())
+				if flagRunRecords && dr.Header().EventType.IsRunRecord() {
+					fmt.Fprintln(out, dr.Header())
+				}
 				continue
 			}
 			log.Fatal(err)
@@ -107,16 +273,9 @@ func main() {
 		if len(ten) != n {
 			log.Fatalf("inconsistent slice returned : size is %d while I was expecting %d", len(ten), n)
 		}
-		if flagNoDispatch {
-			continue
-		}
 
-		err = sampa.Dispatch(ten, elinks, flagMaskELink)
+		handle(ten)
 
-		if err != nil {
-			log.Printf("ten size is %d", len(ten))
-			log.Fatal(err)
-		}
 		if r.NofGBTwords() > 100000 && flagMemProfile != "" {
 			f, err := os.Create(flagMemProfile)
 			if err != nil {
@@ -129,6 +288,10 @@ func main() {
 	}
 }
 
+func printPacket(packet *sampa.Packet, _ origin) {
+	fmt.Println(packet.String())
+}
+
 func dumpElinks(elinks []sampa.ELink) {
 	for i := 0; i < len(elinks); i++ {
 		e := elinks[i]
@@ -138,3 +301,41 @@ func dumpElinks(elinks []sampa.ELink) {
 		i++
 	}
 }
+
+// seek uses the event index of the input file to go
+// directly to the first event to be decoded
+func seek(r *date.DateReader, inputFileName string, filter date.EventFilter) {
+	idx, err := r.Index()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println(idx.Len(), "events in index")
+	if flagSaveIndex {
+		err = idx.Save(inputFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if flagSkip > 0 {
+		err = r.SeekEvent(flagSkip)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !filter.From.IsZero() && flagSkip == 0 && flagEvent == "" {
+		err = r.SeekTime(filter.From)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if flagEvent != "" {
+		id, err := date.ParseEventID(flagEvent)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = r.SeekEventID(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
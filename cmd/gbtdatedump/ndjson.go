@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagNDJSON string
+
+func init() {
+	flag.StringVar(&flagNDJSON, "ndjson", "", "instead of printing the packets, write them as one JSON object per line into `file` (- for the standard output)")
+}
+
+// jsonCluster is the JSON representation of a sampa.Cluster
+type jsonCluster struct {
+	Timestamp int   `json:"ts"`
+	Samples   []int `json:"samples"`
+}
+
+// jsonPacket is the JSON representation of a sampa.Packet
+// and of where it was found in the input
+type jsonPacket struct {
+	EventID string `json:"event,omitempty"` // period:orbit:bc, DATE inputs only
+	Link    *int   `json:"link,omitempty"`  // multi-link inputs only
+	GBTWord int    `json:"gbtWord"`         // index of the GBT (or user logic) word completing the packet
+	ELink   int    `json:"elink"`
+
+	Hamming  uint8  `json:"hamming"`
+	P        bool   `json:"p"`
+	PKT      uint8  `json:"pkt"`
+	NumWords uint16 `json:"numWords"`
+	Hadd     uint8  `json:"hadd"`
+	CHadd    uint8  `json:"chadd"`
+	BXcount  uint32 `json:"bx"`
+	DP       bool   `json:"dp"`
+
+	Truncated       bool `json:"truncated"`
+	TriggerTooEarly bool `json:"triggerTooEarly"`
+	NumWordsAnomaly bool `json:"numWordsAnomaly"`
+
+	Corrected bool   `json:"corrected"`       // the header had a single bit error, corrected
+	Error     string `json:"error,omitempty"` // error found while decoding the payload
+
+	Clusters []jsonCluster `json:"clusters"`
+}
+
+// newJSONPacket returns the JSON representation of the packet
+func newJSONPacket(p *sampa.Packet) jsonPacket {
+	h := p.Header()
+	j := jsonPacket{
+		ELink:    p.ELink(),
+		Hamming:  h.Hamming(),
+		P:        h.P(),
+		PKT:      h.PKT(),
+		NumWords: h.NumWords(),
+		Hadd:     h.Hadd(),
+		CHadd:    h.CHadd(),
+		BXcount:  h.BXcount(),
+		DP:       h.DP(),
+		Clusters: []jsonCluster{},
+	}
+	flags := sampa.HeaderFlags(h)
+	j.Truncated = flags&sampa.FlagTruncated != 0
+	j.TriggerTooEarly = flags&sampa.FlagTriggerTooEarly != 0
+	j.NumWordsAnomaly = flags&sampa.FlagNumWords != 0
+	j.Corrected = p.Corrected()
+	if p.Err() != nil {
+		j.Error = p.Err().Error()
+	}
+	for _, c := range p.Clusters() {
+		j.Clusters = append(j.Clusters, jsonCluster{c.Timestamp(), c.Samples()})
+	}
+	return j
+}
+
+// setupNDJSON makes the packets be written as NDJSON. It returns
+// the function to be called once all the packets are written.
+func setupNDJSON(dr *date.DateReader) func() {
+	var f *os.File
+	if flagNDJSON == "-" {
+		f = os.Stdout
+	} else {
+		var err error
+		f, err = os.Create(flagNDJSON)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	addSink(func(p *sampa.Packet, o origin) {
+		j := newJSONPacket(p)
+		j.GBTWord = o.word
+		j.EventID = currentEventID(dr)
+		if o.link >= 0 {
+			j.Link = &o.link
+		}
+		if err := enc.Encode(j); err != nil {
+			log.Fatal(err)
+		}
+	})
+	return func() {
+		if err := w.Flush(); err != nil {
+			log.Fatal(err)
+		}
+		if flagNDJSON != "-" {
+			if err := f.Close(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func TestNewJSONPacket(t *testing.T) {
+	p, err := sampa.NewPacket(sampa.DataTruncatedPKT, 5, 2, 9, 777, []sampa.Cluster{sampa.NewCluster(3, []int{10, 20})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := newJSONPacket(p)
+	if j.ELink != 5 || j.Hadd != 2 || j.CHadd != 9 || j.BXcount != 777 || j.NumWords != 4 || j.PKT != uint8(sampa.DataTruncatedPKT) {
+		t.Errorf("Unexpected packet %+v", j)
+	}
+	if !j.Truncated || j.TriggerTooEarly || j.NumWordsAnomaly || j.Corrected || j.Error != "" {
+		t.Errorf("Expected a truncated packet without decoding error got %+v", j)
+	}
+	if !reflect.DeepEqual(j.Clusters, []jsonCluster{{3, []int{10, 20}}}) {
+		t.Errorf("Unexpected clusters %v", j.Clusters)
+	}
+
+	hb, _ := sampa.NewHeartBeatPacket(1, 2, 0)
+	b, err := json.Marshal(newJSONPacket(hb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if !strings.Contains(s, `"clusters":[]`) || strings.Contains(s, `"link"`) || strings.Contains(s, `"event"`) || strings.Contains(s, `"error"`) {
+		t.Errorf("Expected empty clusters and no link, event nor error got %s", s)
+	}
+}
+
+func TestReportWriter(t *testing.T) {
+	defer func() { flagNDJSON, flagCSV = "", "" }()
+	flagNDJSON, flagCSV = "-", "out.csv"
+	if w, err := reportWriter(); err != nil || w != os.Stderr {
+		t.Errorf("Expected the reports on the standard error got %v %v", w, err)
+	}
+	flagCSV = "-"
+	if _, err := reportWriter(); err == nil {
+		t.Errorf("Expected an error with two data outputs on the standard output")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/occupancy"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagOccupancy string
+var flagOccupancyChips string
+var occupancyCounter *occupancy.Counter
+
+func init() {
+	flag.StringVar(&flagOccupancy, "occupancy", "", "occupancy report : instead of printing the packets, write the hit map of the channels into `name`.csv and print it as a grid")
+	flag.StringVar(&flagOccupancyChips, "occupancy-chips", "", "comma separated list of the elink:hadd chips expected to send data, the chips not sending any being reported dead, e.g. 0-39:0-1")
+}
+
+// setupOccupancy makes the packets go to the occupancy counter
+func setupOccupancy() {
+	occupancyCounter = occupancy.NewCounter()
+	chips, err := occupancy.ParseChips(flagOccupancyChips)
+	if err != nil {
+		log.Fatal(err)
+	}
+	occupancyCounter.Expect(chips...)
+	addSink(func(p *sampa.Packet, _ origin) {
+		occupancyCounter.Add(p)
+	})
+}
+
+// writeOccupancy writes the hit map and reports the dead and hot
+// channels into w
+func writeOccupancy(w io.Writer) {
+	channels := occupancyCounter.Channels()
+	f, err := os.Create(flagOccupancy + ".csv")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = occupancy.WriteCSV(f, channels); err != nil {
+		log.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err = occupancy.WriteGrid(w, channels); err != nil {
+		log.Fatal(err)
+	}
+	for _, chip := range occupancyCounter.MissingChips() {
+		fmt.Fprintf(w, "dead chip elink %d hadd %d : no packet\n", chip.ELink, chip.Hadd)
+	}
+	var ndead, nhot int
+	for _, ch := range channels {
+		if ch.Dead {
+			ndead++
+		}
+		if ch.Hot {
+			nhot++
+			fmt.Fprintf(w, "hot channel elink %d hadd %d chadd %d : %d clusters\n", ch.ELink, ch.Hadd, ch.CHadd, ch.Clusters)
+		}
+	}
+	fmt.Fprintf(w, "%d chips (%d dead) %d channels : %d dead %d hot (median %g clusters)\n",
+		len(occupancyCounter.Chips()), len(occupancyCounter.MissingChips()), len(channels), ndead, nhot, occupancy.Median(channels))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/pedestal"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagPedestals string
+var pedestals *pedestal.Accumulator
+
+func init() {
+	flag.StringVar(&flagPedestals, "pedestals", "", "pedestal run analysis : instead of printing the packets, write the pedestal table of each channel into `name`.csv and name.json")
+}
+
+// setupPedestals makes the packets go to the pedestal accumulator
+func setupPedestals() {
+	pedestals = pedestal.NewAccumulator()
+	addSink(func(p *sampa.Packet, _ origin) {
+		pedestals.Add(p)
+	})
+}
+
+// writePedestals writes the pedestal tables
+func writePedestals() {
+	peds := pedestals.Pedestals()
+	log.Println(len(peds), "channels in the pedestal table")
+	for _, ext := range []string{".csv", ".json"} {
+		f, err := os.Create(flagPedestals + ext)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if ext == ".csv" {
+			err = pedestal.WriteCSV(f, peds)
+		} else {
+			err = pedestal.WriteJSON(f, peds)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
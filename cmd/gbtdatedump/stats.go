@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/hist"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagStats string
+
+// decodingErrors counts the decoding errors of each elink
+var decodingErrors = hist.NewH1("decoding errors per elink", sampa.NofELinks, -0.5, float64(sampa.NofELinks)-0.5)
+
+// statHist is a histogram of the statistics, written into name_key.csv
+type statHist struct {
+	key string
+	h   *hist.H1
+}
+
+// statistics are the histograms of the decoded packets
+var statistics []statHist
+
+func init() {
+	flag.StringVar(&flagStats, "stats", "", "histogram the ADC values, cluster sizes, peak positions, bunch crossings and packet sizes, print them and write them into `name`_*.csv")
+}
+
+// countDecodingError fills the decoding error histogram
+func countDecodingError(err error) {
+	if e, ok := err.(*sampa.ELinkError); ok {
+		decodingErrors.Fill(float64(e.ELink))
+		return
+	}
+	decodingErrors.Fill(-1)
+}
+
+// setupStats makes the packets go through the statistics
+// histograms before being handled
+func setupStats() {
+	adc := hist.NewH1("ADC values", 1024, -0.5, 1023.5)
+	size := hist.NewH1("cluster sizes", 100, 0.5, 100.5)
+	peak := hist.NewH1("peak position in cluster", 64, -0.5, 63.5)
+	bx := hist.NewH1("bunch crossing counters", 1024, 0, 1<<20)
+	words := hist.NewH1("packet sizes (10-bit words)", 1024, -0.5, 1023.5)
+	statistics = []statHist{
+		{"adc", adc},
+		{"clustersize", size},
+		{"peak", peak},
+		{"bx", bx},
+		{"packetsize", words},
+		{"errors", decodingErrors},
+	}
+	next := handlePacket
+	handlePacket = func(p *sampa.Packet, o origin) {
+		bx.Fill(float64(p.Header().BXcount()))
+		words.Fill(float64(p.Header().NumWords()))
+		for _, c := range p.Clusters() {
+			size.Fill(float64(len(c.Samples())))
+			if _, i := c.Peak(); i >= 0 {
+				peak.Fill(float64(i))
+			}
+			for _, s := range c.Samples() {
+				adc.Fill(float64(s))
+			}
+		}
+		next(p, o)
+	}
+}
+
+// writeStats prints the histograms into w and, if asked for,
+// writes them in CSV
+func writeStats(w io.Writer) {
+	for _, s := range statistics {
+		if err := s.h.WriteText(w); err != nil {
+			log.Fatal(err)
+		}
+		if flagStats == "" {
+			continue
+		}
+		f, err := os.Create(flagStats + "_" + s.key + ".csv")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = s.h.WriteCSV(f); err != nil {
+			log.Fatal(err)
+		}
+		if err = f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
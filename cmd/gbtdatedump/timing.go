@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/fit"
+	"github.com/mrrtf/sampa/pkg/hist"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagTiming string
+
+// maxClusterTime is the upper limit of the time histograms, in
+// sampling periods (the timestamps are 10 bits)
+const maxClusterTime = 1024
+
+// clusterTimes is the time of arrival of the clusters
+var clusterTimes = hist.NewH1("cluster time", 128, 0, maxClusterTime)
+
+// clusterTimesPerELink is the time of arrival of the clusters of each elink
+var clusterTimesPerELink = hist.NewH2("cluster time vs elink",
+	sampa.NofELinks, -0.5, float64(sampa.NofELinks)-0.5, 32, 0, maxClusterTime)
+
+// nofTimeFits counts the clusters timed by the fit, the others
+// being timed by their peak
+var nofTimeFits, nofPeakTimes int
+
+func init() {
+	flag.StringVar(&flagTiming, "timing", "", "timing report : fit the clusters (fast fit), histogram their time of arrival, overall and per elink, print them and write them into `name`_time.csv and `name`_elink.csv")
+}
+
+// setupTiming makes the packets go to the time histograms
+func setupTiming() {
+	fitter := fit.NewFitter()
+	fitter.Fast = true
+	addSink(func(p *sampa.Packet, _ origin) {
+		for _, c := range p.Clusters() {
+			t := clusterTime(fitter, c)
+			clusterTimes.Fill(t)
+			clusterTimesPerELink.Fill(float64(p.ELink()), t)
+		}
+	})
+}
+
+// clusterTime returns the fitted time of arrival of the cluster or,
+// if the fit does not converge (e.g. too few samples), its peak time
+func clusterTime(fitter *fit.Fitter, c sampa.Cluster) float64 {
+	if r := fitter.Fit(c); r.Converged {
+		nofTimeFits++
+		return r.Time
+	}
+	nofPeakTimes++
+	return c.PeakTime()
+}
+
+// writeTiming prints the time histograms into w and writes them
+// into name_time.csv and name_elink.csv
+func writeTiming(w io.Writer) {
+	if err := clusterTimes.WriteText(w); err != nil {
+		log.Fatal(err)
+	}
+	if err := clusterTimesPerELink.WriteText(w); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(w, "%d clusters timed by the fit, %d by their peak\n", nofTimeFits, nofPeakTimes)
+	writeCSVFile(flagTiming+"_time.csv", clusterTimes.WriteCSV)
+	writeCSVFile(flagTiming+"_elink.csv", clusterTimesPerELink.WriteCSV)
+}
+
+// writeCSVFile creates the file name and writes into it
+func writeCSVFile(name string, write func(io.Writer) error) {
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = write(f); err != nil {
+		log.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
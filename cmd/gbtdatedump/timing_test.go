@@ -0,0 +1,24 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/fit"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func TestClusterTime(t *testing.T) {
+	fitter := fit.NewFitter()
+	fitter.Fast = true
+	samples := make([]int, 8)
+	for i := range samples {
+		samples[i] = int(math.Floor(fit.Shape(float64(i), 500, 1.3, 50, fit.DefaultTau) + 0.5))
+	}
+	if tm := clusterTime(fitter, sampa.NewCluster(100, samples)); math.Abs(tm-101.3) > 0.1 {
+		t.Errorf("Expected a fitted time of 101.3 got %g", tm)
+	}
+	if tm := clusterTime(fitter, sampa.NewCluster(100, []int{12})); tm != 100 {
+		t.Errorf("Expected the peak time 100 of a single sample got %g", tm)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagTrace string
+var flagTraceELinks string
+
+// traceWriter is where the elinks trace their state transitions, if not nil
+var traceWriter *bufio.Writer
+
+// tracedELinks tells which elinks are traced
+var tracedELinks [sampa.NofELinks]bool
+
+func init() {
+	flag.StringVar(&flagTrace, "trace", "", "write the state transitions of the elink decoders (sync search, header and data read) into `file`")
+	flag.StringVar(&flagTraceELinks, "trace-elinks", "", "comma separated list of the elinks to trace (default all)")
+}
+
+// prefixWriter prefixes each write, i.e. each trace record,
+// with the link it comes from
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+}
+
+func (pw prefixWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(pw.w, pw.prefix); err != nil {
+		return 0, err
+	}
+	return pw.w.Write(b)
+}
+
+// setupTrace opens the trace file and starts tracing the single link
+// elinks. It returns the function to be called once the decoding is over.
+func setupTrace() func() {
+	for _, s := range splitList(flagTraceELinks) {
+		e, err := strconv.Atoi(s)
+		if err != nil || e < 0 || e >= sampa.NofELinks {
+			log.Fatalf("invalid elink %q to trace", s)
+		}
+		tracedELinks[e] = true
+	}
+	if flagTraceELinks == "" {
+		for i := range tracedELinks {
+			tracedELinks[i] = true
+		}
+	}
+	f, err := os.Create(flagTrace)
+	if err != nil {
+		log.Fatal(err)
+	}
+	traceWriter = bufio.NewWriter(f)
+	traceELinks(elinks, -1)
+	for link, e := range linkElinks {
+		traceELinks(e, link)
+	}
+	return func() {
+		if err := traceWriter.Flush(); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// traceELinks enables the tracing of the chosen elinks of a link
+// (of the single link if link is negative)
+func traceELinks(e []sampa.ELink, link int) {
+	if traceWriter == nil {
+		return
+	}
+	var w io.Writer = traceWriter
+	if link >= 0 {
+		w = prefixWriter{traceWriter, fmt.Sprintf("link %d ", link)}
+	}
+	for i, l := range e {
+		if t, ok := l.(sampa.Decoder); ok && tracedELinks[i] {
+			t.SetTrace(w)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/mrrtf/sampa/pkg/rdh"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagUserLogic bool
+
+func init() {
+	flag.BoolVar(&flagUserLogic, "user-logic", false, "the CRU pages carry 64 bits user logic words instead of GBT words (rdh format only)")
+}
+
+// decodeUserLogic decodes the user logic words of all the pages of r,
+// and reports what has been read into out
+func decodeUserLogic(out io.Writer, r *rdh.Reader) {
+	nwords := 0
+	nerrors := 0
+	for {
+		if flagMaxGBTwords > 0 && nwords >= flagMaxGBTwords {
+			break
+		}
+		w, err := r.ReadUint64()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			log.Fatal(err)
+		}
+		nwords++
+		if flagNoDispatch {
+			continue
+		}
+		// the 5 bits link id of the word identifies the GBT link within the CRU end point
+		link := r.Link()&^0xFF | sampa.UserLogicWord(w).LinkID()
+		o := origin{word: nwords - 1, link: link}
+		err = sampa.DispatchUserLogic(w, elinksOfLink(link), flagMaskELink, func(p *sampa.Packet) {
+			handlePacket(p, o)
+		})
+		if err == sampa.ErrUserLogicError {
+			nerrors++
+			continue
+		}
+		if err != nil {
+			countDecodingError(err)
+			log.Println(err)
+		}
+	}
+	fmt.Fprintf(out, "Happy ending. I've read %d CRU pages of %d links and %d user logic words\n",
+		r.NofPages(), len(linkElinks), nwords)
+	if nerrors > 0 {
+		fmt.Fprintf(out, "%d user logic words with errors\n", nerrors)
+	}
+	if decodingErrors.Entries() > 0 {
+		fmt.Fprintf(out, "%d user logic words with decoding errors\n", decodingErrors.Entries())
+	}
+}
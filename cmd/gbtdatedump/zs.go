@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/mrrtf/sampa/pkg/pedestal"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+var flagPedestalTable string
+var flagZSThreshold float64
+var flagZSSigma bool
+var flagZSTrim bool
+
+func init() {
+	flag.StringVar(&flagPedestalTable, "pedestal-table", "", "pedestal table (CSV or JSON) to subtract from the samples")
+	flag.Float64Var(&flagZSThreshold, "zs-threshold", 0, "zero suppression threshold, in ADC counts above pedestal (requires -pedestal-table)")
+	flag.BoolVar(&flagZSSigma, "zs-sigma", false, "zero suppression threshold is in units of the channel noise")
+	flag.BoolVar(&flagZSTrim, "zs-trim", false, "trim the clusters to their samples above threshold instead of keeping them whole")
+}
+
+// setupZeroSuppression makes the packets go through the pedestal
+// subtraction and zero suppression before being handled
+func setupZeroSuppression() *pedestal.Suppressor {
+	table, err := pedestal.LoadTable(flagPedestalTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println(len(table), "channels in the pedestal table")
+	z := pedestal.NewSuppressor(table)
+	z.Threshold = flagZSThreshold
+	z.InSigma = flagZSSigma
+	z.Trim = flagZSTrim
+	next := handlePacket
+	handlePacket = func(p *sampa.Packet, o origin) {
+		p, err := z.Apply(p)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if p != nil {
+			next(p, o)
+		}
+	}
+	return z
+}
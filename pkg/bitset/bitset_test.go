@@ -254,7 +254,7 @@ func TestFromIntegers(t *testing.T) {
 	}
 	err = bs.SetRangeFromUint8(0, 7, 0xFF)
 	if err != nil {
-		t.Errorf("should have been able to assign 255 to a 8-bit bitset !", err)
+		t.Errorf("should have been able to assign 255 to a 8-bit bitset ! %v", err)
 	}
 	if bs.Length() != 8 {
 		t.Errorf("bs should be of length 8 and is %d", bs.Length())
@@ -18,20 +18,20 @@ var blue = color.New(color.FgBlue).SprintFunc()
 var red = color.New(color.FgRed).SprintFunc()
 
 type EventHeaderType struct {
-	EventSize         uint32    //  [ 0: 4[
-	EventMagic        uint32    //    4: 8
-	HeaderSize        uint32    //    8:12
-	Version           uint32    //   12:16
-	EventType         uint32    //   16:20
-	RunNumber         uint32    //   20:24
-	EventID           uint64    //   24:32
-	Trigger           [2]uint64 //   32:48
-	Detectors         uint32    //   48:52
-	Attributes        [3]uint32 //   52:64
-	Ldc               uint32    //   64:68
-	Gdc               uint32    //   68:72
-	TimeStampSec      uint32    //   72:76
-	TimeStampMicroSec uint32    //   76:80
+	EventSize         uint32     //  [ 0: 4[
+	EventMagic        uint32     //    4: 8
+	HeaderSize        uint32     //    8:12
+	Version           uint32     //   12:16
+	EventType         EventKind  //   16:20
+	RunNumber         uint32     //   20:24
+	EventID           EventID    //   24:32
+	Trigger           [2]uint64  //   32:48
+	Detectors         uint32     //   48:52
+	Attributes        Attributes //   52:64
+	Ldc               uint32     //   64:68
+	Gdc               uint32     //   68:72
+	TimeStampSec      uint32     //   72:76
+	TimeStampMicroSec uint32     //   76:80
 }
 
 type EquipmentHeaderType struct {
@@ -79,6 +79,12 @@ func (event *EventType) OnlyHeader(header EventHeaderType) {
 	event.size = 0
 }
 
+// Data returns the payload after the SOP.
+//
+// The returned slice is not a copy : it is only valid until the next
+// event is read and, for the events of a memory mapped reader, it is
+// read only (writing to it crashes the program) and must not be used
+// after the reader is closed.
 func (event *EventType) Data() []byte {
 	if !event.HasPayload() {
 		return nil
@@ -99,6 +105,8 @@ func (event *EventType) quartet(pos int) (uint32, uint32, uint32, uint32) {
 }
 
 // start of packet (SOP = 0x000000000000000000000000000001)
+//
+// As for Data, the returned slice is not a copy.
 func (event *EventType) SOP() ([]byte, error) {
 	if !event.HasPayload() {
 		return nil, nil
@@ -110,6 +118,47 @@ func (event *EventType) SOP() ([]byte, error) {
 	return event.payload[28:44], nil
 }
 
+// EOP checks the end of packet word, i.e. the last DATE quartet of the
+// payload (EOP = 0x0000000000000000000000000000XXXX), and returns its
+// 16 bits status field.
+//
+// ErrMissingEOP is returned if the last quartet does not look like an EOP
+// (and is then most probably a GBT word), ErrInvalidEOP if the payload
+// size is not a whole number of quartets or if the last quartet has
+// its 96 high bits cleared but a non zero value above the status field.
+func (event *EventType) EOP() (uint16, error) {
+	n := event.size - equipmentHeaderSize - nDateBytesPerGBT
+	if n < 0 || n%nDateBytesPerGBT != 0 {
+		return 0, ErrInvalidEOP
+	}
+	if n == 0 {
+		return 0, ErrMissingEOP
+	}
+	a, b, c, d := event.quartet(event.size - nDateBytesPerGBT)
+	if a != 0 || b != 0 || c != 0 {
+		return 0, ErrMissingEOP
+	}
+	if d>>16 != 0 {
+		return 0, ErrInvalidEOP
+	}
+	return uint16(d), nil
+}
+
+// nofGBTwords returns the number of GBT words in the payload, i.e. the
+// number of full quartets after the SOP, minus the last one if withEOP
+// is set and it is a valid EOP. A malformed EOP is decoded as a GBT word.
+func (event *EventType) nofGBTwords(withEOP bool) int {
+	m := event.size - equipmentHeaderSize - nDateBytesPerGBT
+	if m < nDateBytesPerGBT {
+		return 0
+	}
+	n := m / nDateBytesPerGBT
+	if _, err := event.EOP(); withEOP && err == nil {
+		n--
+	}
+	return n
+}
+
 func (h EventHeaderType) String() string {
 
 	v := fmt.Sprintf("\n%s ", blue("eveSize "))
@@ -122,20 +171,20 @@ func (h EventHeaderType) String() string {
 	v += fmt.Sprintf("%08X\n", h.Version)
 
 	v += fmt.Sprintf("%s ", blue("eveType "))
-	v += fmt.Sprintf("%08X", h.EventType)
+	v += fmt.Sprintf("%-8s", h.EventType)
 	v += fmt.Sprintf(" %s ", blue("run     "))
 	v += fmt.Sprintf("%08X", h.RunNumber)
 	v += fmt.Sprintf(" %s ", blue("id      "))
-	v += fmt.Sprintf("%016X\n", h.EventID)
+	v += fmt.Sprintf("%016X (%s)\n", uint64(h.EventID), h.EventID)
 
 	v += fmt.Sprintf("%s ", blue("trigger "))
-	v += fmt.Sprintf("%016X%016X\n", h.Trigger[0], h.Trigger[1])
+	v += fmt.Sprintf("%016X%016X %v\n", h.Trigger[0], h.Trigger[1], h.TriggerClasses())
 
 	v += fmt.Sprintf("%s ", blue("dets    "))
-	v += fmt.Sprintf("%08X", h.Detectors)
+	v += fmt.Sprintf("%08X %v", h.Detectors, h.DetectorIDs())
 	v += fmt.Sprintf(" %s ", blue("attr    "))
-	v += fmt.Sprintf("%08X%08X%08X\n", h.Attributes[0],
-		h.Attributes[1], h.Attributes[2])
+	v += fmt.Sprintf("%08X%08X%08X %s\n", h.Attributes[0],
+		h.Attributes[1], h.Attributes[2], h.Attributes)
 
 	v += fmt.Sprintf("%s ", blue("LDC     "))
 	v += fmt.Sprintf("%08X", h.Ldc)
@@ -145,6 +194,7 @@ func (h EventHeaderType) String() string {
 	v += fmt.Sprintf("%08X", h.TimeStampSec)
 	v += fmt.Sprintf(" %s ", blue("time(us)"))
 	v += fmt.Sprintf("%08X", h.TimeStampMicroSec)
+	v += fmt.Sprintf(" (%s)", h.Time().Format("2006-01-02 15:04:05.000000"))
 	return v
 }
 
@@ -163,8 +213,6 @@ func StringPerLine(buf []byte, perline int) string {
 	return v
 }
 
-var nbadsop = 0
-
 func (event *EventType) String() string {
 	v := event.header.String()
 	v += "\n---\n"
@@ -173,10 +221,13 @@ func (event *EventType) String() string {
 		sop, err := event.SOP()
 		v += blue("SOP ") + StringPerLine(sop, 4)
 		if err != nil {
-			nbadsop++
-			if nbadsop > 10 {
-				log.Fatal(err)
-			}
+			v += red(err.Error()) + "\n"
+		}
+		status, err := event.EOP()
+		if err != nil {
+			v += blue("EOP ") + err.Error() + "\n"
+		} else {
+			v += blue("EOP ") + fmt.Sprintf("status %04X\n", status)
 		}
 		// v += blue("DATA\n") + StringPerLine(event.Data()[:16*5], 4)
 	}
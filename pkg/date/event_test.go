@@ -0,0 +1,145 @@
+package date
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// countGBT reads the next event and returns its number of GBT words
+// and the error that ended it
+func countGBT(dr *DateReader) (int, error) {
+	n := 0
+	for {
+		err := dr.NextGBT()
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func TestEOP(t *testing.T) {
+	withStatus := testEvent(PhysicsEvent, 0, 0, 3)
+	binary.LittleEndian.PutUint32(withStatus[len(withStatus)-4:], 0xBEEF)
+	badStatus := testEvent(PhysicsEvent, 0, 0, 3)
+	binary.LittleEndian.PutUint32(badStatus[len(badStatus)-4:], 0x1BEEF)
+
+	filename := writeTestFile(t,
+		testEvent(PhysicsEvent, 0, 0, 3),
+		withStatus,
+		trimEvent(testEvent(PhysicsEvent, 0, 0, 3), nDateBytesPerGBT),
+		trimEvent(testEvent(PhysicsEvent, 0, 0, 3), 4),
+		badStatus)
+	defer removeTestFile(filename)
+
+	expected := []struct {
+		nwords int
+		status uint16
+		err    error
+	}{
+		{3, 0, nil},
+		{3, 0xBEEF, nil},
+		{3, 0, ErrMissingEOP},
+		{3, 0, ErrInvalidEOP},
+		{4, 0, ErrInvalidEOP}, // a malformed EOP is decoded
+	}
+
+	dr := NewReader(filename)
+	for i, e := range expected {
+		n, err := countGBT(dr)
+		if err != ErrEndOfEvent {
+			t.Fatalf("event %d : expected ErrEndOfEvent got %v", i, err)
+		}
+		status, err := dr.EOP()
+		if n != e.nwords || status != e.status || err != e.err {
+			t.Errorf("event %d : expected %d words, status %X, err %v. Got %d, %X, %v",
+				i, e.nwords, e.status, e.err, n, status, err)
+		}
+		if dr.IsDiscontinuity(ErrEndOfEvent) != (e.err != nil) {
+			t.Errorf("event %d : expected a discontinuity %v", i, e.err != nil)
+		}
+	}
+	if dr.NofMissingEOP() != 1 || dr.NofInvalidEOP() != 2 {
+		t.Errorf("Expected 1 missing and 2 invalid EOP, got %d and %d",
+			dr.NofMissingEOP(), dr.NofInvalidEOP())
+	}
+
+	dr = NewReader(filename)
+	dr.RequireEOP(true)
+	var errs []error
+	for {
+		_, err := countGBT(dr)
+		if err == io.EOF {
+			break
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) != 5 || errs[1] != ErrEndOfEvent || errs[2] != ErrMissingEOP || errs[3] != ErrInvalidEOP {
+		t.Errorf("Unexpected errors in strict mode : %v", errs)
+	}
+	if !dr.IsDiscontinuity(errs[2]) || !dr.IsDiscontinuity(errs[3]) {
+		t.Errorf("Expected a discontinuity for the skipped events")
+	}
+}
+
+func TestNoEOP(t *testing.T) {
+	// last GBT word with only elinks 0-7 set, i.e. GBT[79:16]==0,
+	// and no EOP after it
+	event := trimEvent(testEvent(PhysicsEvent, 0, 0, 3), nDateBytesPerGBT)
+	last := event[len(event)-nDateBytesPerGBT:]
+	for i := range last {
+		last[i] = 0
+	}
+	last[12], last[13] = 0xAB, 0xCD
+	filename := writeTestFile(t, event, testEvent(PhysicsEvent, 0, 0, 2))
+	defer removeTestFile(filename)
+
+	dr := NewReader(filename)
+	dr.ExpectEOP(false)
+	n, err := countGBT(dr)
+	if n != 3 || err != ErrEndOfEvent {
+		t.Fatalf("Expected 3 GBT words got %d (%v)", n, err)
+	}
+	if dr.gbt[0] != 0xAB || dr.gbt[1] != 0xCD {
+		t.Errorf("Expected last GBT word to start with AB CD got %X", dr.gbt)
+	}
+	// the EOP of the second event is then a GBT word
+	if n, _ := countGBT(dr); n != 3 {
+		t.Errorf("Expected the EOP to be read as a GBT word, got %d words", n)
+	}
+	if dr.NofMissingEOP() != 0 || dr.NofInvalidEOP() != 0 {
+		t.Errorf("Expected no EOP to be counted got %d and %d", dr.NofMissingEOP(), dr.NofInvalidEOP())
+	}
+}
+
+func TestLayout(t *testing.T) {
+	data := make([]byte, nDateBytesPerGBT)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	gbt := make([]byte, 10)
+	dr := &DateReader{layout: DefaultLayout}
+	dr.Data2GBTHelper(gbt, data)
+	if string(gbt) != string([]byte{12, 13, 14, 15, 8, 9, 10, 11, 4, 5}) {
+		t.Errorf("Unexpected default layout GBT word %v", gbt)
+	}
+	l, err := LayoutByName("legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dr.SetLayout(l)
+	dr.Data2GBTHelper(gbt, data)
+	if string(gbt) != string([]byte{8, 9, 10, 11, 4, 5, 6, 7, 0, 1}) {
+		t.Errorf("Unexpected legacy layout GBT word %v", gbt)
+	}
+	if RegisterLayout(Layout{Name: "bad", Bytes: [10]int{0, 0, 1, 2, 3, 4, 5, 6, 7, 8}}) == nil {
+		t.Errorf("A layout using twice the same byte should be invalid")
+	}
+	if err := RegisterLayout(Layout{Name: "reversed", Bytes: [10]int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(Layouts()) != 3 || Layouts()[2].Name != "reversed" {
+		t.Errorf("Unexpected layouts %v", Layouts())
+	}
+}
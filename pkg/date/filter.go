@@ -0,0 +1,165 @@
+package date
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// As in the TRIGGER_PATTERN and DETECTOR_PATTERN macros of DATE's
+// event.h, bit 0 of the trigger pattern and bit 31 of the detector
+// pattern tell whether the patterns are valid, trigger class i being
+// bit i+1 of the trigger pattern and detector i bit i of the detector
+// pattern.
+const (
+	// MaxTriggerClass is the largest trigger class id
+	MaxTriggerClass = 99
+	// MaxDetector is the largest detector id
+	MaxDetector = 30
+
+	triggerPatternValid  uint64 = 1
+	detectorPatternValid uint32 = 1 << 31
+)
+
+// TriggerPatternValid tells whether the trigger pattern of the
+// event has been validated
+func (h EventHeaderType) TriggerPatternValid() bool {
+	return h.Trigger[0]&triggerPatternValid != 0
+}
+
+// DetectorPatternValid tells whether the detector pattern of the
+// event has been validated
+func (h EventHeaderType) DetectorPatternValid() bool {
+	return h.Detectors&detectorPatternValid != 0
+}
+
+// triggerBit returns the word and the bit of trigger class i
+// in the trigger pattern
+func triggerBit(i uint) (uint, uint64) {
+	return (i + 1) / 64, uint64(1) << ((i + 1) % 64)
+}
+
+// TriggerClasses returns the ids (0-99) of the trigger
+// classes that are set in the trigger pattern of the event
+func (h EventHeaderType) TriggerClasses() []int {
+	var classes []int
+	for i := uint(0); i <= MaxTriggerClass; i++ {
+		if w, bit := triggerBit(i); h.Trigger[w]&bit != 0 {
+			classes = append(classes, int(i))
+		}
+	}
+	return classes
+}
+
+// DetectorIDs returns the ids (0-30) of the detectors
+// that are set in the detector pattern of the event
+func (h EventHeaderType) DetectorIDs() []int {
+	var ids []int
+	for i := uint(0); i <= MaxDetector; i++ {
+		if h.Detectors&(uint32(1)<<i) != 0 {
+			ids = append(ids, int(i))
+		}
+	}
+	return ids
+}
+
+// Ordinal returns a number that increases with the event id,
+// i.e. ordered by period, then orbit, then bunch crossing
+func (id EventID) Ordinal() uint64 {
+	return uint64(id.Period())<<36 | uint64(id.Orbit())<<12 | uint64(id.BunchCrossing())
+}
+
+var ErrInvalidEventID = errors.New("date: invalid event id (expected period:orbit:bc)")
+
+// ParseEventID decodes an event id written as period:orbit:bc
+func ParseEventID(s string) (EventID, error) {
+	var period, orbit uint32
+	var bc uint16
+	n, err := fmt.Sscanf(s, "%d:%d:%d", &period, &orbit, &bc)
+	if err != nil || n != 3 {
+		return 0, ErrInvalidEventID
+	}
+	if period > 0xFFFFFFF || orbit > 0xFFFFFF || bc > 0xFFF {
+		return 0, ErrInvalidEventID
+	}
+	return NewEventID(period, orbit, bc), nil
+}
+
+// EventFilter describes which DATE events are to be decoded.
+// The zero value selects all the events.
+type EventFilter struct {
+	// Types, if not empty, is the list of event types to keep
+	Types []EventKind
+	// TriggerMask, if not zero, keeps only the events having
+	// at least one of those trigger classes
+	TriggerMask [2]uint64
+	// DetectorMask, if not zero, keeps only the events having
+	// at least one of those detectors
+	DetectorMask uint32
+	// FirstID and LastID, if not zero, are the (inclusive) limits
+	// of the range of event ids to keep
+	FirstID EventID
+	LastID  EventID
+	// From and To, if not zero, are the limits of the time window
+	// of the events to keep (From included, To excluded)
+	From time.Time
+	To   time.Time
+}
+
+// Accept returns true if the event with the given header
+// passes all the conditions of the filter
+func (f *EventFilter) Accept(h EventHeaderType) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, k := range f.Types {
+			if k == h.EventType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	// the masks never hold the validity bits
+	if f.TriggerMask[0] != 0 || f.TriggerMask[1] != 0 {
+		if f.TriggerMask[0]&h.Trigger[0] == 0 && f.TriggerMask[1]&h.Trigger[1] == 0 {
+			return false
+		}
+	}
+	if f.DetectorMask != 0 && f.DetectorMask&h.Detectors == 0 {
+		return false
+	}
+	if f.FirstID != 0 && h.EventID.Ordinal() < f.FirstID.Ordinal() {
+		return false
+	}
+	if f.LastID != 0 && h.EventID.Ordinal() > f.LastID.Ordinal() {
+		return false
+	}
+	if !f.From.IsZero() && h.Time().Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !h.Time().Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// SetTriggerClass adds trigger class i (0-99) to the trigger mask
+func (f *EventFilter) SetTriggerClass(i uint) error {
+	if i > MaxTriggerClass {
+		return errors.New(fmt.Sprintf("date: trigger class %d out of range", i))
+	}
+	w, bit := triggerBit(i)
+	f.TriggerMask[w] |= bit
+	return nil
+}
+
+// SetDetector adds detector i (0-30) to the detector mask
+func (f *EventFilter) SetDetector(i uint) error {
+	if i > MaxDetector {
+		return errors.New(fmt.Sprintf("date: detector %d out of range", i))
+	}
+	f.DetectorMask |= uint32(1) << i
+	return nil
+}
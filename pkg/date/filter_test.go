@@ -0,0 +1,79 @@
+package date
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTriggerClassesAndDetectors(t *testing.T) {
+	h := EventHeaderType{Trigger: [2]uint64{0x5, 0x1000000000 | 0x1}, Detectors: 0x80000002}
+	if !reflect.DeepEqual(h.TriggerClasses(), []int{1, 63, 99}) {
+		t.Errorf("Unexpected trigger classes %v", h.TriggerClasses())
+	}
+	if !reflect.DeepEqual(h.DetectorIDs(), []int{1}) {
+		t.Errorf("Unexpected detector ids %v", h.DetectorIDs())
+	}
+	if !h.TriggerPatternValid() || !h.DetectorPatternValid() {
+		t.Errorf("Expected valid trigger and detector patterns")
+	}
+	h = EventHeaderType{Trigger: [2]uint64{0x2, 0}, Detectors: 0x1}
+	if h.TriggerPatternValid() || h.DetectorPatternValid() || !reflect.DeepEqual(h.TriggerClasses(), []int{0}) {
+		t.Errorf("Expected trigger class 0 in invalid patterns got %v", h.TriggerClasses())
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	h := EventHeaderType{EventType: PhysicsEvent, Trigger: [2]uint64{0x1, 0x4}, Detectors: 0x80000004,
+		EventID: NewEventID(1, 100, 10)}
+	var f EventFilter
+	if !f.Accept(h) {
+		t.Errorf("empty filter should accept everything")
+	}
+	f.Types = []EventKind{CalibrationEvent}
+	if f.Accept(h) {
+		t.Errorf("physics event should not pass a calibration filter")
+	}
+	f.Types = append(f.Types, PhysicsEvent)
+	f.SetTriggerClass(0)
+	if f.Accept(h) {
+		t.Errorf("event should not pass trigger class 0, whatever its validity bit")
+	}
+	f.SetTriggerClass(65)
+	f.SetDetector(2)
+	if !f.Accept(h) {
+		t.Errorf("event should pass trigger class 65 and detector 2")
+	}
+	f.FirstID, _ = ParseEventID("1:100:11")
+	if f.Accept(h) {
+		t.Errorf("event is before FirstID")
+	}
+	f.FirstID = NewEventID(0, 0xFFFFFF, 0)
+	f.LastID = NewEventID(1, 100, 10)
+	if !f.Accept(h) {
+		t.Errorf("event is within [FirstID,LastID]")
+	}
+	if f.SetTriggerClass(100) == nil || f.SetDetector(31) == nil {
+		t.Errorf("out of range trigger class or detector should be an error")
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	h := EventHeaderType{TimeStampSec: 1492161900, TimeStampMicroSec: 500}
+	if h.Time().Unix() != 1492161900 || h.Time().Nanosecond() != 500000 {
+		t.Errorf("Unexpected time %v", h.Time())
+	}
+	f := EventFilter{From: time.Unix(1492161900, 0), To: time.Unix(1492161901, 0)}
+	if !f.Accept(h) {
+		t.Errorf("event should be within the time window")
+	}
+	f.From = time.Unix(1492161900, 600000)
+	if f.Accept(h) {
+		t.Errorf("event is before the time window")
+	}
+	f.From = time.Time{}
+	f.To = h.Time()
+	if f.Accept(h) {
+		t.Errorf("To should be excluded from the time window")
+	}
+}
@@ -0,0 +1,188 @@
+package date
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventKind is the type of a DATE event (start of run, physics, ...)
+type EventKind uint32
+
+const (
+	StartOfRun                   EventKind = 1
+	EndOfRun                     EventKind = 2
+	StartOfRunFiles              EventKind = 3
+	EndOfRunFiles                EventKind = 4
+	StartOfBurst                 EventKind = 5
+	EndOfBurst                   EventKind = 6
+	PhysicsEvent                 EventKind = 7
+	CalibrationEvent             EventKind = 8
+	EventFormatError             EventKind = 9
+	StartOfData                  EventKind = 10
+	EndOfData                    EventKind = 11
+	SystemSoftwareTriggerEvent   EventKind = 12
+	DetectorSoftwareTriggerEvent EventKind = 13
+	SyncEvent                    EventKind = 14
+)
+
+var eventKindNames = map[EventKind]string{
+	StartOfRun:                   "SOR",
+	EndOfRun:                     "EOR",
+	StartOfRunFiles:              "SORF",
+	EndOfRunFiles:                "EORF",
+	StartOfBurst:                 "SOB",
+	EndOfBurst:                   "EOB",
+	PhysicsEvent:                 "PHYSICS",
+	CalibrationEvent:             "CALIBRATION",
+	EventFormatError:             "FORMAT_ERROR",
+	StartOfData:                  "SOD",
+	EndOfData:                    "EOD",
+	SystemSoftwareTriggerEvent:   "SST",
+	DetectorSoftwareTriggerEvent: "DST",
+	SyncEvent:                    "SYNC",
+}
+
+var ErrUnknownEventKind = errors.New("date: unknown event type")
+
+// ParseEventKind returns the EventKind corresponding to name,
+// which is one of the short names returned by EventKind.String
+// (case does not matter)
+func ParseEventKind(name string) (EventKind, error) {
+	for k, v := range eventKindNames {
+		if strings.EqualFold(v, name) {
+			return k, nil
+		}
+	}
+	return 0, ErrUnknownEventKind
+}
+
+func (k EventKind) String() string {
+	if v, ok := eventKindNames[k]; ok {
+		return v
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", uint32(k))
+}
+
+// IsRunRecord returns true for the events marking the start
+// or the end of a run (SOR,EOR,SORF,EORF)
+func (k EventKind) IsRunRecord() bool {
+	return k == StartOfRun || k == EndOfRun ||
+		k == StartOfRunFiles || k == EndOfRunFiles
+}
+
+// Time returns the absolute time at which the event was recorded
+func (h EventHeaderType) Time() time.Time {
+	return time.Unix(int64(h.TimeStampSec), int64(h.TimeStampMicroSec)*1000)
+}
+
+// EventID is the collider event identification : 28 bits period,
+// 24 bits orbit and 12 bits bunch crossing.
+//
+// The first DATE word (low 32 bits) holds the 4 high bits of the orbit
+// in bits 0-3 and the period in bits 4-31.
+// The second DATE word (high 32 bits) holds the bunch crossing in bits
+// 0-11 and the 20 low bits of the orbit in bits 12-31.
+type EventID uint64
+
+// NewEventID builds the EventID of the given period, orbit and bunch crossing
+func NewEventID(period, orbit uint32, bc uint16) EventID {
+	lo := (period&0xFFFFFFF)<<4 | (orbit>>20)&0xF
+	hi := (orbit&0xFFFFF)<<12 | uint32(bc)&0xFFF
+	return EventID(uint64(lo) | uint64(hi)<<32)
+}
+
+func (id EventID) BunchCrossing() uint16 {
+	return uint16(id>>32) & 0xFFF
+}
+
+func (id EventID) Orbit() uint32 {
+	return (uint32(id)&0xF)<<20 | uint32(id>>44)&0xFFFFF
+}
+
+func (id EventID) Period() uint32 {
+	return uint32(id) >> 4
+}
+
+func (id EventID) String() string {
+	return fmt.Sprintf("period %d orbit %d bc %d", id.Period(), id.Orbit(), id.BunchCrossing())
+}
+
+// SystemAttribute is the index of one of the 32 system attribute bits
+// of a DATE event (i.e. bits 64-95 of the attribute pattern)
+type SystemAttribute uint
+
+const (
+	AttrPhaseStart    SystemAttribute = 0
+	AttrPhaseEnd      SystemAttribute = 1
+	AttrSwapped       SystemAttribute = 2
+	AttrPaged         SystemAttribute = 3
+	AttrSuperEvent    SystemAttribute = 4
+	AttrOrbitBC       SystemAttribute = 5
+	AttrKeepPages     SystemAttribute = 6
+	AttrHLTDecision   SystemAttribute = 7
+	AttrByDetector    SystemAttribute = 28
+	AttrDataTruncated SystemAttribute = 29
+	AttrError         SystemAttribute = 30
+	AttrFlushed       SystemAttribute = 31
+)
+
+var systemAttributeNames = []struct {
+	attr SystemAttribute
+	name string
+}{
+	{AttrPhaseStart, "PHASE_START"},
+	{AttrPhaseEnd, "PHASE_END"},
+	{AttrSwapped, "SWAPPED"},
+	{AttrPaged, "PAGED"},
+	{AttrSuperEvent, "SUPER_EVENT"},
+	{AttrOrbitBC, "ORBIT_BC"},
+	{AttrKeepPages, "KEEP_PAGES"},
+	{AttrHLTDecision, "HLT_DECISION"},
+	{AttrByDetector, "BY_DETECTOR"},
+	{AttrDataTruncated, "DATA_TRUNCATED"},
+	{AttrError, "ERROR"},
+	{AttrFlushed, "FLUSHED"},
+}
+
+// Attributes is the 96 bits attribute pattern of a DATE event.
+// Bits 0-63 are user attributes, bits 64-95 are system attributes.
+type Attributes [3]uint32
+
+// User returns the 64 user attribute bits
+func (a Attributes) User() uint64 {
+	return uint64(a[0]) | uint64(a[1])<<32
+}
+
+// HasUser returns true if user attribute bit i (0-63) is set
+func (a Attributes) HasUser(i uint) bool {
+	return i < 64 && a.User()&(uint64(1)<<i) != 0
+}
+
+// System returns the 32 system attribute bits
+func (a Attributes) System() uint32 {
+	return a[2]
+}
+
+// Has returns true if the given system attribute is set
+func (a Attributes) Has(s SystemAttribute) bool {
+	return s < 32 && a.System()&(uint32(1)<<s) != 0
+}
+
+// String returns the names of the system attributes that are set,
+// followed by the indices of the user attributes that are set
+func (a Attributes) String() string {
+	var v []string
+	for _, s := range systemAttributeNames {
+		if a.Has(s.attr) {
+			v = append(v, s.name)
+		}
+	}
+	for i := uint(0); i < 64; i++ {
+		if a.HasUser(i) {
+			v = append(v, fmt.Sprintf("U%d", i))
+		}
+	}
+	return strings.Join(v, "|")
+}
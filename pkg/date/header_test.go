@@ -0,0 +1,56 @@
+package date
+
+import "testing"
+
+func TestEventID(t *testing.T) {
+	id := NewEventID(0xABCDEF1, 0x123456, 0xFED)
+	if id.Period() != 0xABCDEF1 {
+		t.Errorf("Expected period 0x%X got 0x%X", 0xABCDEF1, id.Period())
+	}
+	if id.Orbit() != 0x123456 {
+		t.Errorf("Expected orbit 0x%X got 0x%X", 0x123456, id.Orbit())
+	}
+	if id.BunchCrossing() != 0xFED {
+		t.Errorf("Expected bc 0x%X got 0x%X", 0xFED, id.BunchCrossing())
+	}
+	// first DATE word = period and orbit high bits, second = orbit low bits and bc
+	if uint32(id) != 0xABCDEF11 || uint32(id>>32) != 0x23456FED {
+		t.Errorf("Unexpected layout %016X", uint64(id))
+	}
+}
+
+func TestParseEventKind(t *testing.T) {
+	for k := StartOfRun; k <= SyncEvent; k++ {
+		p, err := ParseEventKind(k.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != k {
+			t.Errorf("Expected %v got %v", k, p)
+		}
+	}
+	k, err := ParseEventKind("physics")
+	if err != nil || k != PhysicsEvent {
+		t.Errorf("physics should be parsed as %v", PhysicsEvent)
+	}
+	_, err = ParseEventKind("toto")
+	if err != ErrUnknownEventKind {
+		t.Errorf("toto should not be a valid event type")
+	}
+	if !EndOfRun.IsRunRecord() || PhysicsEvent.IsRunRecord() {
+		t.Errorf("IsRunRecord is wrong")
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	a := Attributes{0x1, 0x80000000, 1<<uint(AttrOrbitBC) | 1<<uint(AttrError)}
+	if !a.Has(AttrOrbitBC) || !a.Has(AttrError) || a.Has(AttrSwapped) {
+		t.Errorf("Wrong system attributes %08X", a.System())
+	}
+	if !a.HasUser(0) || !a.HasUser(63) || a.HasUser(1) {
+		t.Errorf("Wrong user attributes %016X", a.User())
+	}
+	if a.String() != "ORBIT_BC|ERROR|U0|U63" {
+		t.Errorf("Unexpected string %s", a.String())
+	}
+}
@@ -0,0 +1,67 @@
+package date
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// testEvent returns the bytes of a DATE event made of a single
+// equipment holding a SOP, nwords GBT words and an EOP
+func testEvent(kind EventKind, id EventID, sec uint32, nwords int) []byte {
+	ndatabytes := 0
+	if nwords >= 0 {
+		ndatabytes = equipmentHeaderSize + (nwords+2)*nDateBytesPerGBT
+	}
+	b := make([]byte, int(headerSize)+ndatabytes)
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)))
+	binary.LittleEndian.PutUint32(b[4:8], magic)
+	binary.LittleEndian.PutUint32(b[8:12], headerSize)
+	binary.LittleEndian.PutUint32(b[16:20], uint32(kind))
+	binary.LittleEndian.PutUint64(b[24:32], uint64(id))
+	binary.LittleEndian.PutUint32(b[72:76], sec)
+	if ndatabytes == 0 {
+		return b
+	}
+	p := b[headerSize:]
+	binary.LittleEndian.PutUint32(p[0:4], uint32(ndatabytes))
+	sop := p[equipmentHeaderSize:]
+	binary.LittleEndian.PutUint32(sop[12:16], 1)
+	for i := 0; i < nwords; i++ {
+		w := sop[(i+1)*nDateBytesPerGBT:]
+		for j := 4; j < 14; j++ {
+			w[j] = byte(i + j)
+		}
+	}
+	return b
+}
+
+// writeTestFile writes the given events into a temporary file
+// and returns its name
+func writeTestFile(tb testing.TB, events ...[]byte) string {
+	f, err := ioutil.TempFile("", "datetest")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	for _, e := range events {
+		if _, err := f.Write(e); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return f.Name()
+}
+
+func removeTestFile(filename string) {
+	os.Remove(filename)
+	os.Remove(IndexFileName(filename))
+}
+
+// trimEvent removes the last n bytes of the event built by testEvent
+func trimEvent(b []byte, n int) []byte {
+	b = b[:len(b)-n]
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)))
+	binary.LittleEndian.PutUint32(b[headerSize:headerSize+4], uint32(len(b))-headerSize)
+	return b
+}
@@ -0,0 +1,197 @@
+package date
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	ErrInvalidIndex  = errors.New("date: invalid index file")
+	ErrStaleIndex    = errors.New("date: index does not match data file")
+	ErrEventNotFound = errors.New("date: event not found")
+)
+
+const (
+	indexMagic     uint32 = 0xDA1E1DE8
+	indexVersion   uint32 = 1
+	indexEntrySize        = 28
+)
+
+// IndexEntry locates one DATE event within a file
+type IndexEntry struct {
+	Offset            int64  // offset of the event header in the file
+	Size              uint32 // size of the event, header included
+	EventID           EventID
+	TimeStampSec      uint32
+	TimeStampMicroSec uint32
+}
+
+// Time returns the absolute time at which the event was recorded
+func (e IndexEntry) Time() time.Time {
+	return time.Unix(int64(e.TimeStampSec), int64(e.TimeStampMicroSec)*1000)
+}
+
+// Index lists all the events of a DATE file, in file order
+type Index struct {
+	FileSize int64 // size of the indexed data file
+	Entries  []IndexEntry
+}
+
+// IndexFileName returns the name of the sidecar index file of
+// the given DATE file
+func IndexFileName(filename string) string {
+	return filename + ".idx"
+}
+
+// BuildIndex walks once through the given DATE file, reading only
+// the event headers and seeking past the payloads
+func BuildIndex(filename string) (*Index, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{FileSize: fi.Size()}
+	buf := make([]byte, headerSize)
+	var h EventHeaderType
+	var offset int64
+	for offset < idx.FileSize {
+		_, err := file.ReadAt(buf, offset)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("date: cannot read header at offset %d : %v", offset, err))
+		}
+		decodeHeader(buf, &h)
+		if h.EventMagic != magic {
+			return nil, errors.New(fmt.Sprintf("date: no magic word at offset %d", offset))
+		}
+		size := h.EventSize
+		if size < headerSize {
+			size = headerSize
+		}
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Offset:            offset,
+			Size:              size,
+			EventID:           h.EventID,
+			TimeStampSec:      h.TimeStampSec,
+			TimeStampMicroSec: h.TimeStampMicroSec})
+		offset += int64(size)
+	}
+	return idx, nil
+}
+
+// Len returns the number of indexed events
+func (idx *Index) Len() int {
+	return len(idx.Entries)
+}
+
+// Find returns the position in the index of the first event
+// with the given id
+func (idx *Index) Find(id EventID) (int, error) {
+	for i, e := range idx.Entries {
+		if e.EventID == id {
+			return i, nil
+		}
+	}
+	return -1, ErrEventNotFound
+}
+
+// WriteTo writes the index in its binary (sidecar) format
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, indexEntrySize)
+	binary.LittleEndian.PutUint32(buf[0:4], indexMagic)
+	binary.LittleEndian.PutUint32(buf[4:8], indexVersion)
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(idx.FileSize))
+	binary.LittleEndian.PutUint64(buf[16:24], uint64(len(idx.Entries)))
+	n, err := bw.Write(buf[:24])
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+	for _, e := range idx.Entries {
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(e.Offset))
+		binary.LittleEndian.PutUint32(buf[8:12], e.Size)
+		binary.LittleEndian.PutUint64(buf[12:20], uint64(e.EventID))
+		binary.LittleEndian.PutUint32(buf[20:24], e.TimeStampSec)
+		binary.LittleEndian.PutUint32(buf[24:28], e.TimeStampMicroSec)
+		n, err = bw.Write(buf)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+// ReadIndex reads an index written by WriteTo
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	buf := make([]byte, indexEntrySize)
+	if _, err := io.ReadFull(br, buf[:24]); err != nil {
+		return nil, ErrInvalidIndex
+	}
+	if binary.LittleEndian.Uint32(buf[0:4]) != indexMagic ||
+		binary.LittleEndian.Uint32(buf[4:8]) != indexVersion {
+		return nil, ErrInvalidIndex
+	}
+	idx := &Index{FileSize: int64(binary.LittleEndian.Uint64(buf[8:16]))}
+	n := binary.LittleEndian.Uint64(buf[16:24])
+	for i := uint64(0); i < n; i++ {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, ErrInvalidIndex
+		}
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Offset:            int64(binary.LittleEndian.Uint64(buf[0:8])),
+			Size:              binary.LittleEndian.Uint32(buf[8:12]),
+			EventID:           EventID(binary.LittleEndian.Uint64(buf[12:20])),
+			TimeStampSec:      binary.LittleEndian.Uint32(buf[20:24]),
+			TimeStampMicroSec: binary.LittleEndian.Uint32(buf[24:28])})
+	}
+	return idx, nil
+}
+
+// Save writes the index into the sidecar file of the given DATE file
+func (idx *Index) Save(filename string) error {
+	f, err := os.Create(IndexFileName(filename))
+	if err != nil {
+		return err
+	}
+	_, err = idx.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadIndex reads the sidecar index file of the given DATE file.
+// ErrStaleIndex is returned if the index does not correspond
+// to the current size of the data file.
+func LoadIndex(filename string) (*Index, error) {
+	f, err := os.Open(IndexFileName(filename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	idx, err := ReadIndex(f)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() != idx.FileSize {
+		return nil, ErrStaleIndex
+	}
+	return idx, nil
+}
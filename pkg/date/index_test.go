@@ -0,0 +1,90 @@
+package date
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	filename := writeTestFile(t,
+		testEvent(StartOfRun, 0, 100, -1),
+		testEvent(PhysicsEvent, NewEventID(0, 1, 10), 101, 3),
+		testEvent(PhysicsEvent, NewEventID(0, 2, 20), 102, 5),
+		testEvent(EndOfRun, 0, 103, -1))
+	defer removeTestFile(filename)
+
+	idx, err := BuildIndex(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 4 {
+		t.Fatalf("Expected 4 events, got %d", idx.Len())
+	}
+	if idx.Entries[2].Offset != int64(idx.Entries[1].Offset)+int64(idx.Entries[1].Size) {
+		t.Errorf("Inconsistent offsets %v", idx.Entries)
+	}
+	if idx.Entries[3].TimeStampSec != 103 {
+		t.Errorf("Expected timestamp 103 got %d", idx.Entries[3].TimeStampSec)
+	}
+
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	idx2, err := ReadIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(idx, idx2) {
+		t.Errorf("Index not the same after write/read")
+	}
+
+	if err := idx.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIndex(filename); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	filename := writeTestFile(t,
+		testEvent(PhysicsEvent, NewEventID(0, 1, 10), 101, 3),
+		testEvent(PhysicsEvent, NewEventID(0, 2, 20), 102, 5),
+		testEvent(PhysicsEvent, NewEventID(0, 3, 30), 103, 7))
+	defer removeTestFile(filename)
+
+	dr := NewReader(filename)
+	if err := dr.SeekEvent(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := dr.GetNextEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if dr.Header().TimeStampSec != 103 {
+		t.Errorf("Expected third event, got %v", dr.Header())
+	}
+	if err := dr.SeekEventID(NewEventID(0, 2, 20)); err != nil {
+		t.Fatal(err)
+	}
+	if err := dr.GetNextEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if dr.Header().EventID != NewEventID(0, 2, 20) {
+		t.Errorf("Expected second event, got %v", dr.Header())
+	}
+	if err := dr.SeekTime(time.Unix(102, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := dr.GetNextEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if dr.Header().TimeStampSec != 103 {
+		t.Errorf("Expected third event, got %v", dr.Header())
+	}
+	if err := dr.SeekEventID(NewEventID(0, 4, 40)); err != ErrEventNotFound {
+		t.Errorf("Expected ErrEventNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package date
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Layout describes where the 10 bytes of a GBT word are
+// within the 16 bytes of the DATE quartet holding it :
+// byte i of the GBT word is byte Bytes[i] of the quartet.
+//
+// The layout depends on the version of the G-RORC firmware
+// used to record the data.
+type Layout struct {
+	Name  string
+	Bytes [10]int
+}
+
+var (
+	// DefaultLayout is the layout of the current firmware
+	DefaultLayout = Layout{Name: "default", Bytes: [10]int{12, 13, 14, 15, 8, 9, 10, 11, 4, 5}}
+	// LegacyLayout is the layout of older recordings
+	LegacyLayout = Layout{Name: "legacy", Bytes: [10]int{8, 9, 10, 11, 4, 5, 6, 7, 0, 1}}
+)
+
+var ErrUnknownLayout = errors.New("date: unknown GBT word layout")
+
+var layouts = make(map[string]Layout)
+
+func init() {
+	RegisterLayout(DefaultLayout)
+	RegisterLayout(LegacyLayout)
+}
+
+// RegisterLayout makes a layout available to LayoutByName.
+// An existing layout with the same name is replaced.
+func RegisterLayout(l Layout) error {
+	if l.Name == "" {
+		return errors.New("date: a layout must have a name")
+	}
+	used := make(map[int]bool)
+	for i, b := range l.Bytes {
+		if b < 0 || b >= nDateBytesPerGBT || used[b] {
+			return errors.New(fmt.Sprintf("date: layout %s : invalid position %d for GBT byte %d", l.Name, b, i))
+		}
+		used[b] = true
+	}
+	layouts[l.Name] = l
+	return nil
+}
+
+// LayoutByName returns the registered layout with the given name
+func LayoutByName(name string) (Layout, error) {
+	l, ok := layouts[name]
+	if !ok {
+		return Layout{}, ErrUnknownLayout
+	}
+	return l, nil
+}
+
+// Layouts returns all the registered layouts, sorted by name
+func Layouts() []Layout {
+	var v []Layout
+	for _, l := range layouts {
+		v = append(v, l)
+	}
+	sort.Sort(byName(v))
+	return v
+}
+
+type byName []Layout
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func (l Layout) String() string {
+	return fmt.Sprintf("%s %v", l.Name, l.Bytes)
+}
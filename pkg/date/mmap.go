@@ -0,0 +1,85 @@
+package date
+
+import (
+	"errors"
+	"io"
+	"log"
+)
+
+var ErrMmapUnsupported = errors.New("date: memory mapping not available")
+
+// NewMmapReader returns a DateReader object ready to read from the
+// given filename, which is memory mapped instead of being read
+// through a buffer. The payload of the events then points directly
+// into the mapping, without any copy.
+//
+// If the file cannot be mapped (e.g. not on Linux) a regular
+// buffered reader is returned.
+func NewMmapReader(filename string) *DateReader {
+	dr := NewReader(filename)
+	if dr == nil {
+		return nil
+	}
+	mapped, err := mmapFile(dr.file)
+	if err != nil {
+		log.Println("cannot mmap", filename, ":", err, "- using buffered reads")
+		return dr
+	}
+	dr.mapped = mapped
+	return dr
+}
+
+// IsMapped returns true if the reader is memory mapped
+func (dr *DateReader) IsMapped() bool {
+	return dr.mapped != nil
+}
+
+// getMappedEvent makes the event found at the current offset of the
+// mapping the current event, and returns its size
+func (dr *DateReader) getMappedEvent() (int64, error) {
+	if dr.offset >= int64(len(dr.mapped)) {
+		return 0, io.EOF
+	}
+	b := dr.mapped[dr.offset:]
+	if len(b) < int(headerSize) {
+		return int64(len(b)), io.ErrUnexpectedEOF
+	}
+	event := dr.event
+	event.size = 0
+	err := event.fillHeader(b[:headerSize])
+	if err != nil {
+		// skip to the next header, if any
+		i := findHeader(b[1:])
+		if i < 0 {
+			return int64(len(b)), err
+		}
+		return int64(i + 1), err
+	}
+	if event.header.EventSize <= headerSize {
+		return int64(headerSize), ErrEmptyEvent
+	}
+	size := int64(event.header.EventSize)
+	if !dr.filter.Accept(event.header) {
+		return size, ErrSkipped
+	}
+	if len(b) < int(event.header.EventSize) {
+		return int64(len(b)), io.ErrUnexpectedEOF
+	}
+	// the payload aliases the read only mapping (see Data)
+	event.payload = b[headerSize:event.header.EventSize:event.header.EventSize]
+	event.size = len(event.payload)
+	return size, event.checkEquipment()
+}
+
+func (dr *DateReader) unmap() error {
+	if dr.mapped == nil {
+		return nil
+	}
+	err := munmap(dr.mapped)
+	dr.mapped = nil
+	// the current event must not point to the unmapped memory
+	dr.event.payload = nil
+	dr.event.size = 0
+	dr.pos = -1
+	return err
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package date
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the whole file in memory, read only
+func mmapFile(f *os.File) ([]byte, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		return nil, ErrMmapUnsupported
+	}
+	return syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package date
+
+import "os"
+
+// mmapFile is only implemented for Linux
+func mmapFile(f *os.File) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package date
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	var events [][]byte
+	for i := 0; i < 20; i++ {
+		events = append(events, testEvent(PhysicsEvent, NewEventID(0, uint32(i), 0), 0, i%5-1))
+	}
+	filename := writeTestFile(t, events...)
+	defer removeTestFile(filename)
+
+	dr := NewReader(filename)
+	ids, words := readAll(t, dr)
+	dr.Close()
+
+	dr = NewMmapReader(filename)
+	defer dr.Close()
+	if !dr.IsMapped() {
+		t.Skip("memory mapping not available")
+	}
+	mids, mwords := readAll(t, dr)
+	if !reflect.DeepEqual(ids, mids) || !reflect.DeepEqual(words, mwords) {
+		t.Errorf("mmap : events or GBT words differ from the buffered read")
+	}
+	if err := dr.SeekEvent(13); err != nil {
+		t.Fatal(err)
+	}
+	if err := dr.GetNextEvent(); err != nil || dr.Header().EventID.Orbit() != 13 {
+		t.Errorf("expected event 13 after seek, got %v (%v)", dr.Header().EventID, err)
+	}
+	// the payload is not a copy but points into the mapping
+	if &dr.event.payload[0] != &dr.mapped[dr.Offset()-int64(len(events[13]))+int64(headerSize)] {
+		t.Errorf("payload is not a slice of the mapping")
+	}
+}
+
+func BenchmarkReadMmap(b *testing.B) {
+	benchmarkRead(b, NewMmapReader)
+}
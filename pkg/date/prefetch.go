@@ -0,0 +1,111 @@
+package date
+
+import (
+	"bufio"
+	"sync"
+)
+
+// prefetcher reads events ahead of the caller in a goroutine,
+// using a fixed pool of event buffers
+type prefetcher struct {
+	n       int
+	results chan prefetched
+	free    chan *EventType
+	done    chan struct{}
+	wg      sync.WaitGroup
+	current *EventType // event (from the pool) in use by the caller
+	err     error      // error that stopped the goroutine
+}
+
+type prefetched struct {
+	event *EventType
+	n     int64 // number of bytes consumed
+	err   error
+}
+
+// Prefetch makes the reader read up to n events in advance, in a
+// background goroutine, while the caller is decoding the current one.
+// A value of n <= 0 stops the prefetching.
+//
+// The reader should be closed (see Close) to stop the goroutine.
+//
+// Prefetching is useless, and thus ignored, for memory mapped readers.
+func (dr *DateReader) Prefetch(n int) {
+	dr.stopPrefetch()
+	if n <= 0 || dr.mapped != nil {
+		return
+	}
+	p := &prefetcher{
+		n:       n,
+		results: make(chan prefetched, n),
+		free:    make(chan *EventType, n+2),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < n+2; i++ {
+		p.free <- NewEvent()
+	}
+	p.wg.Add(1)
+	go p.run(dr.r, dr.filter)
+	dr.prefetch = p
+}
+
+// run reads events into the free buffers until the end of the input,
+// a read error, or until it is told to stop
+func (p *prefetcher) run(r *bufio.Reader, filter EventFilter) {
+	defer p.wg.Done()
+	defer close(p.results)
+	for {
+		var event *EventType
+		select {
+		case event = <-p.free:
+		case <-p.done:
+			return
+		}
+		n, err := readEvent(r, event, &filter)
+		select {
+		case p.results <- prefetched{event, n, err}:
+		case <-p.done:
+			return
+		}
+		if err != nil && !IsEndOfEvent(err) {
+			return
+		}
+	}
+}
+
+// getPrefetchedEvent makes the next prefetched event the current one
+func (dr *DateReader) getPrefetchedEvent() (int64, error) {
+	p := dr.prefetch
+	if p.current != nil {
+		p.free <- p.current
+		p.current = nil
+	}
+	res, ok := <-p.results
+	if !ok {
+		return 0, p.err
+	}
+	if res.err != nil && !IsEndOfEvent(res.err) {
+		p.err = res.err
+	}
+	p.current = res.event
+	dr.event = res.event
+	return res.n, res.err
+}
+
+// stopPrefetch stops the prefetching goroutine, if any, and returns
+// the number of events it was prefetching.
+// The events already prefetched but not yet used are lost.
+func (dr *DateReader) stopPrefetch() int {
+	p := dr.prefetch
+	if p == nil {
+		return 0
+	}
+	close(p.done)
+	p.wg.Wait()
+	if p.current != nil {
+		// keep the current event readable after the pool is gone
+		dr.event = p.current
+	}
+	dr.prefetch = nil
+	return p.n
+}
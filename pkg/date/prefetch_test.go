@@ -0,0 +1,160 @@
+package date
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// readAll returns the event ids and the GBT words of the whole file
+func readAll(tb testing.TB, dr *DateReader) ([]EventID, []byte) {
+	var ids []EventID
+	var words []byte
+	ten := make([]byte, 10)
+	for {
+		_, err := dr.Read(ten)
+		if err == io.EOF {
+			return ids, words
+		}
+		if IsEndOfEvent(err) {
+			ids = append(ids, dr.Header().EventID)
+			continue
+		}
+		if err != nil {
+			tb.Fatal(err)
+		}
+		words = append(words, ten...)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	var events [][]byte
+	for i := 0; i < 50; i++ {
+		kind := PhysicsEvent
+		if i%7 == 0 {
+			kind = CalibrationEvent
+		}
+		events = append(events, testEvent(kind, NewEventID(0, uint32(i), 0), 0, i%11))
+	}
+	events = append(events, testEvent(EndOfRun, 0, 0, -1))
+	filename := writeTestFile(t, events...)
+	defer removeTestFile(filename)
+
+	dr := NewReader(filename)
+	dr.SelectEventTypes(PhysicsEvent, EndOfRun)
+	ids, words := readAll(t, dr)
+	dr.Close()
+
+	for _, n := range []int{1, 4, 100} {
+		dr = NewReader(filename)
+		dr.SelectEventTypes(PhysicsEvent, EndOfRun)
+		dr.Prefetch(n)
+		pids, pwords := readAll(t, dr)
+		if !reflect.DeepEqual(ids, pids) || !reflect.DeepEqual(words, pwords) {
+			t.Errorf("prefetch(%d) : events or GBT words differ from the synchronous read", n)
+		}
+		if dr.NofEvents() != len(events) {
+			t.Errorf("prefetch(%d) : expected %d events got %d", n, len(events), dr.NofEvents())
+		}
+		if err := dr.GetNextEvent(); err != io.EOF {
+			t.Errorf("prefetch(%d) : expected EOF after the last event, got %v", n, err)
+		}
+		// seeking restarts the prefetching at the right place
+		if err := dr.SeekEvent(48); err != nil {
+			t.Fatal(err)
+		}
+		if err := dr.GetNextEvent(); err != nil || dr.Header().EventID.Orbit() != 48 {
+			t.Errorf("prefetch(%d) : expected event 48 after seek, got %v", n, dr.Header().EventID)
+		}
+		dr.Close()
+	}
+}
+
+const (
+	nBenchEvents = 100
+	nBenchWords  = 20000
+)
+
+// slowReader emulates a disk reading at a given speed
+type slowReader struct {
+	r           io.Reader
+	nsPerKiByte time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	time.Sleep(time.Duration(n/1024) * s.nsPerKiByte)
+	return n, err
+}
+
+// benchmarkRead reads all the GBT words of a file, doing some
+// fake decoding work on each of them, using the reader returned
+// by open
+func benchmarkRead(b *testing.B, open func(filename string) *DateReader) {
+	var events [][]byte
+	for i := 0; i < nBenchEvents; i++ {
+		events = append(events, testEvent(PhysicsEvent, 0, 0, nBenchWords))
+	}
+	filename := writeTestFile(b, events...)
+	defer removeTestFile(filename)
+	b.SetBytes(int64(nBenchEvents * len(events[0])))
+	b.ResetTimer()
+	sum := 0
+	for i := 0; i < b.N; i++ {
+		dr := open(filename)
+		for {
+			err := dr.NextGBT()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				continue
+			}
+			for _, x := range dr.gbt {
+				for k := uint(0); k < 8; k += 2 {
+					sum += int(x>>k) & 3
+				}
+			}
+		}
+		dr.Close()
+	}
+}
+
+// openSlow returns a reader that reads the file at the pace
+// of a disk of about 200 MB/s, i.e. 5 µs per KiB
+func openSlow(filename string, prefetch int) *DateReader {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	dr := newReader(&slowReader{r: file, nsPerKiByte: 5 * time.Microsecond})
+	dr.file = file
+	dr.Prefetch(prefetch)
+	return dr
+}
+
+func BenchmarkReadSync(b *testing.B) {
+	benchmarkRead(b, NewReader)
+}
+
+func BenchmarkReadPrefetch(b *testing.B) {
+	benchmarkRead(b, func(filename string) *DateReader {
+		dr := NewReader(filename)
+		dr.Prefetch(4)
+		return dr
+	})
+}
+
+func BenchmarkReadSyncSlowDisk(b *testing.B) {
+	benchmarkRead(b, func(filename string) *DateReader {
+		return openSlow(filename, 0)
+	})
+}
+
+func BenchmarkReadPrefetchSlowDisk(b *testing.B) {
+	benchmarkRead(b, func(filename string) *DateReader {
+		return openSlow(filename, 4)
+	})
+}
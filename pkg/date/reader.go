@@ -2,20 +2,57 @@ package date
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"time"
 )
 
 var (
-	ErrEmptyEvent = errors.New("date: empty event")
-	ErrInvalidSOP = errors.New("date: invalid start of packet")
-	ErrEndOfEvent = errors.New("date: end of event")
+	ErrEmptyEvent       = errors.New("date: empty event")
+	ErrInvalidSOP       = errors.New("date: invalid start of packet")
+	ErrEndOfEvent       = errors.New("date: end of event")
+	ErrSkipped          = errors.New("date: event not selected")
+	ErrMissingEOP       = errors.New("date: missing end of packet")
+	ErrInvalidEOP       = errors.New("date: invalid end of packet")
+	ErrNotSeekable      = errors.New("date: reader cannot seek")
+	ErrNoMagic          = errors.New("date: no magic word where an event header was expected")
+	ErrEventTooLarge    = errors.New("date: event size exceeds the maximum payload size")
+	ErrInvalidEquipment = errors.New("date: event with several equipments or an inconsistent equipment size")
+	ErrInvalidGBTSize   = errors.New("date: Read expects a 10 bytes slice")
 )
 
+// HasMagic returns true if b starts with a DATE event header
+func HasMagic(b []byte) bool {
+	return len(b) >= 8 && binary.LittleEndian.Uint32(b[4:8]) == magic
+}
+
+// magicBytes is the magic word as found in the files
+var magicBytes = []byte{0xFE, 0x5A, 0x1E, 0xDA}
+
+// findHeader returns the position of the first event header
+// in b, or -1 if there is none
+func findHeader(b []byte) int {
+	if len(b) < 8 {
+		return -1
+	}
+	return bytes.Index(b[4:], magicBytes)
+}
+
+// IsEndOfEvent returns true if err is one of the errors Read
+// and NextGBT use to signal that the current event is done with
+func IsEndOfEvent(err error) bool {
+	return err == ErrEndOfEvent || err == ErrEmptyEvent ||
+		err == ErrInvalidSOP || err == ErrSkipped ||
+		err == ErrMissingEOP || err == ErrInvalidEOP ||
+		err == ErrNoMagic || err == ErrEventTooLarge ||
+		err == ErrInvalidEquipment
+}
+
 const (
 	magic            uint32 = 0xDA1E5AFE
 	nDateWordsPerGBT        = 4 // 4 x 32 bits words
@@ -25,14 +62,26 @@ const (
 // DateReader is meant to read GBT words from a DATE
 // file.
 type DateReader struct {
-	r       io.Reader
-	event   *EventType
-	pos     int
-	gbt     []byte
-	headBuf []byte
-	header  EventHeaderType
-	nevents int
-	ngbt    int
+	name     string
+	file     *os.File
+	r        *bufio.Reader
+	offset   int64 // file offset of the next event
+	index    *Index
+	event    *EventType
+	pos      int
+	gbt      []byte
+	header   EventHeaderType
+	nevents  int
+	ngbt     int
+	filter   EventFilter
+	nwords   int  // number of GBT words of the current event
+	strict   bool // skip events without a valid EOP
+	noEOP    bool // events do not end with an EOP
+	badEOP   bool // the current event does not end with a valid EOP
+	nbadeop  map[error]int
+	layout   Layout
+	prefetch *prefetcher
+	mapped   []byte // whole file, if memory mapped
 }
 
 // NewReader returns a DateReader object ready
@@ -43,41 +92,88 @@ func NewReader(filename string) *DateReader {
 		log.Println(err)
 		return nil
 	}
-	return &DateReader{r: bufio.NewReader(file), event: NewEvent(), pos: -1, gbt: make([]byte, 10), headBuf: make([]byte, headerSize), nevents: 0, ngbt: 0}
+	dr := newReader(file)
+	dr.name = filename
+	dr.file = file
+	return dr
+}
+
+// NewReaderFrom returns a DateReader reading the events from r,
+// e.g. from memory. Such a reader cannot seek.
+func NewReaderFrom(r io.Reader) *DateReader {
+	return newReader(r)
+}
+
+// newReader returns a DateReader reading from r.
+// Such a reader cannot seek.
+func newReader(r io.Reader) *DateReader {
+	return &DateReader{r: bufio.NewReader(r), event: NewEvent(), layout: DefaultLayout, nbadeop: make(map[error]int), pos: -1, gbt: make([]byte, 10), nevents: 0, ngbt: 0}
 }
 
 var gbtcount int = 0
 
+// Close stops the prefetching, if any, and closes the underlying file
+func (dr *DateReader) Close() error {
+	dr.stopPrefetch()
+	if err := dr.unmap(); err != nil {
+		return err
+	}
+	if dr.file == nil {
+		return nil
+	}
+	return dr.file.Close()
+}
+
+// SetFilter sets the conditions the events must fulfill to be decoded.
+// Events that do not pass the filter are skipped without reading their
+// payload.
+// It must be called before Prefetch to be taken into account by
+// the prefetching.
+func (dr *DateReader) SetFilter(f EventFilter) {
+	dr.filter = f
+}
+
+// SelectEventTypes restricts the events that are decoded to the given
+// types. Calling it without argument selects all the types (the default).
+func (dr *DateReader) SelectEventTypes(kinds ...EventKind) {
+	dr.filter.Types = kinds
+}
+
+// Header returns the header of the current event
+func (dr *DateReader) Header() EventHeaderType {
+	return dr.header
+}
+
 // Read reads 10 bytes from the underlying stream
 // FIXME: this is not really satisfying the Read interface
 // (e.g. we really expect p to be of len 10, nothing else...)
+//
+// Each DATE event ends with exactly one of the errors for which
+// IsEndOfEvent is true, after which the Header of the event
+// is still available.
 func (dr *DateReader) Read(p []byte) (n int, err error) {
 	if len(p) != 10 {
-		log.Fatal("DateReader.Read method is so far only able to deal with 10 bytes slices")
+		return 0, ErrInvalidGBTSize
 	}
-	for {
-		err = dr.NextGBT()
-		if err == io.EOF {
-			return 0, err
-		}
-		if err == nil {
-			copy(p, dr.gbt)
-			gbtcount++
-			// fmt.Printf("GBT word %d = %s\n", gbtcount, dr.GBTAsString())
-			return len(dr.gbt), nil
-		}
+	err = dr.NextGBT()
+	if err != nil {
 		if err == ErrEndOfEvent {
 			gbtcount = 0
-			return 0, err
 		}
+		return 0, err
 	}
+	copy(p, dr.gbt)
+	gbtcount++
+	// fmt.Printf("GBT word %d = %s\n", gbtcount, dr.GBTAsString())
+	return len(dr.gbt), nil
 }
 
 // NextGBT advances to the next 10 bytes representing a single 80-bit GBT word
 // and fills the gbt internal slice with those
 //
 // Note that the DATE events without payload or with incorrect start
-// of (sampa) packet are simply skipped by NextGBT
+// of (sampa) packet are simply skipped by NextGBT, as well as, if
+// RequireEOP is set, the events without a valid end of packet.
 func (dr *DateReader) NextGBT() (err error) {
 
 	if dr.pos < 0 {
@@ -98,12 +194,24 @@ func (dr *DateReader) NextGBT() (err error) {
 			log.Println("Event with invalid SOP. Skipping")
 			return ErrInvalidSOP
 		}
+		dr.badEOP = false
+		if !dr.noEOP {
+			_, err = dr.event.EOP()
+			if err != nil {
+				dr.nbadeop[err]++
+				dr.badEOP = true
+				if dr.strict {
+					return err
+				}
+			}
+		}
+		dr.nwords = dr.event.nofGBTwords(!dr.noEOP)
 		dr.pos = 0
 		// log.Printf("SOE %d len of data %d size %d", dr.event.Header().EventID,
 		// 	len(dr.event.Data()), dr.event.size)
 	}
 
-	endOfEvent := dr.pos+nDateWordsPerGBT >= dr.event.size-equipmentHeaderSize-2*nDateBytesPerGBT
+	endOfEvent := dr.pos >= dr.nwords*nDateBytesPerGBT
 
 	if endOfEvent {
 		// log.Println("EOE reached. Going to next event")
@@ -113,34 +221,25 @@ func (dr *DateReader) NextGBT() (err error) {
 
 	dr.Data2GBT(dr.pos)
 	dr.pos += nDateBytesPerGBT
-
-	if len(dr.gbt) != 10 {
-		log.Fatal("dr.gbt is not of size 10")
-	}
 	return nil
 }
 
+// Data2GBTHelper extracts the 10 bytes of a GBT word from the 16 bytes
+// of a DATE quartet, according to the layout of the reader
 func (dr *DateReader) Data2GBTHelper(gbt []byte, data []byte) {
-	gbt[0] = data[8+4]
-	gbt[1] = data[9+4]
-	gbt[2] = data[10+4]
-	gbt[3] = data[11+4]
-	gbt[4] = data[4+4]
-	gbt[5] = data[5+4]
-	gbt[6] = data[6+4]
-	gbt[7] = data[7+4]
-	gbt[8] = data[0+4]
-	gbt[9] = data[1+4]
-	// gbt[0] = data[8]
-	// gbt[1] = data[9]
-	// gbt[2] = data[10]
-	// gbt[3] = data[11]
-	// gbt[4] = data[4]
-	// gbt[5] = data[5]
-	// gbt[6] = data[6]
-	// gbt[7] = data[7]
-	// gbt[8] = data[0]
-	// gbt[9] = data[1]
+	for i, b := range dr.layout.Bytes {
+		gbt[i] = data[b]
+	}
+}
+
+// SetLayout sets the layout of the GBT words within the DATE quartets
+func (dr *DateReader) SetLayout(l Layout) {
+	dr.layout = l
+}
+
+// Layout returns the layout of the GBT words used by the reader
+func (dr *DateReader) Layout() Layout {
+	return dr.layout
 }
 
 // Data2GBT converts 3 32-bits (DATE) words into a 80-bits GBT word
@@ -151,6 +250,64 @@ func (dr *DateReader) Data2GBT(pos int) {
 	dr.ngbt++
 }
 
+// RequireEOP sets whether the events without a valid end of packet
+// word are decoded (the default) or skipped. Requiring an EOP implies
+// expecting one (see ExpectEOP).
+func (dr *DateReader) RequireEOP(strict bool) {
+	dr.strict = strict
+	if strict {
+		dr.noEOP = false
+	}
+}
+
+// ExpectEOP sets whether the events end with an end of packet word
+// (the default). If so, the last quartet of each event is checked
+// and, if it is a valid EOP, not decoded as a GBT word : a missing or
+// invalid EOP is counted and the quartet is decoded as a GBT word.
+// Otherwise, for equipments that do not write EOPs, all the quartets
+// after the SOP are decoded as GBT words, whatever they look like,
+// and nothing is counted.
+func (dr *DateReader) ExpectEOP(expect bool) {
+	dr.noEOP = !expect
+	if !expect {
+		dr.strict = false
+	}
+}
+
+// EOP returns the status field of the end of packet word of the current
+// event, or ErrMissingEOP or ErrInvalidEOP if it does not have a valid one
+func (dr *DateReader) EOP() (uint16, error) {
+	return dr.event.EOP()
+}
+
+// NofMissingEOP returns the number of events read so far
+// without an end of packet word
+func (dr *DateReader) NofMissingEOP() int {
+	return dr.nbadeop[ErrMissingEOP]
+}
+
+// NofInvalidEOP returns the number of events read so far
+// with a malformed end of packet word
+func (dr *DateReader) NofInvalidEOP() int {
+	return dr.nbadeop[ErrInvalidEOP]
+}
+
+// IsDiscontinuity returns true if err, returned by Read, means that
+// the GBT words read after it do not follow the ones read before :
+// bytes were skipped to find the next event header, an event was
+// skipped as corrupted, or the event just read does not end with a
+// valid end of packet (e.g. it is truncated). The elinks must then
+// look for a sync again.
+func (dr *DateReader) IsDiscontinuity(err error) bool {
+	switch err {
+	case ErrEndOfEvent:
+		return dr.badEOP
+	case ErrInvalidSOP, ErrMissingEOP, ErrInvalidEOP, ErrNoMagic, ErrEventTooLarge, ErrInvalidEquipment:
+		return true
+	}
+	return false
+}
+
 func (dr *DateReader) NofEvents() int {
 	return dr.nevents
 }
@@ -159,76 +316,237 @@ func (dr *DateReader) NofGBTwords() int {
 	return dr.ngbt
 }
 
-func (dr *DateReader) mustFillHeader() {
+// fillHeader decodes the header of event from b
+func (event *EventType) fillHeader(b []byte) error {
+	decodeHeader(b, &event.header)
+	if event.header.EventMagic != magic {
+		return ErrNoMagic
+	}
+	if event.header.EventSize > headerSize+maxPayloadSize {
+		return ErrEventTooLarge
+	}
+	return nil
+}
+
+// decodeHeader fills h from the headerSize bytes of b
+func decodeHeader(b []byte, h *EventHeaderType) {
 	// this ain't pretty but is (much) faster than
 	// using the binary.Read on the header struct itself...
-	dr.header.EventSize = binary.LittleEndian.Uint32(dr.headBuf[:4])
-	dr.header.EventMagic = binary.LittleEndian.Uint32(dr.headBuf[4:8])
-	dr.header.HeaderSize = binary.LittleEndian.Uint32(dr.headBuf[8:12])
-	dr.header.Version = binary.LittleEndian.Uint32(dr.headBuf[12:16])
-	dr.header.EventType = binary.LittleEndian.Uint32(dr.headBuf[16:20])
-	dr.header.RunNumber = binary.LittleEndian.Uint32(dr.headBuf[20:24])
-	dr.header.EventID = binary.LittleEndian.Uint64(dr.headBuf[24:32])
-	dr.header.Trigger[0] = binary.LittleEndian.Uint64(dr.headBuf[32:40])
-	dr.header.Trigger[1] = binary.LittleEndian.Uint64(dr.headBuf[40:48])
-	dr.header.Detectors = binary.LittleEndian.Uint32(dr.headBuf[48:52])
-	dr.header.Attributes[0] = binary.LittleEndian.Uint32(dr.headBuf[52:56])
-	dr.header.Attributes[1] = binary.LittleEndian.Uint32(dr.headBuf[56:60])
-	dr.header.Attributes[2] = binary.LittleEndian.Uint32(dr.headBuf[60:64])
-	dr.header.Ldc = binary.LittleEndian.Uint32(dr.headBuf[64:68])
-	dr.header.Gdc = binary.LittleEndian.Uint32(dr.headBuf[68:72])
-	dr.header.TimeStampSec = binary.LittleEndian.Uint32(dr.headBuf[72:76])
-	dr.header.TimeStampMicroSec = binary.LittleEndian.Uint32(dr.headBuf[76:80])
-	if dr.header.EventMagic != magic {
-		log.Fatalf("no magic word (%X) where I expected it, found %X instead. b=%v", magic, dr.header.EventMagic, dr.headBuf)
-	}
-}
-
-// insure we only have one equipment,
+	h.EventSize = binary.LittleEndian.Uint32(b[:4])
+	h.EventMagic = binary.LittleEndian.Uint32(b[4:8])
+	h.HeaderSize = binary.LittleEndian.Uint32(b[8:12])
+	h.Version = binary.LittleEndian.Uint32(b[12:16])
+	h.EventType = EventKind(binary.LittleEndian.Uint32(b[16:20]))
+	h.RunNumber = binary.LittleEndian.Uint32(b[20:24])
+	h.EventID = EventID(binary.LittleEndian.Uint64(b[24:32]))
+	h.Trigger[0] = binary.LittleEndian.Uint64(b[32:40])
+	h.Trigger[1] = binary.LittleEndian.Uint64(b[40:48])
+	h.Detectors = binary.LittleEndian.Uint32(b[48:52])
+	h.Attributes[0] = binary.LittleEndian.Uint32(b[52:56])
+	h.Attributes[1] = binary.LittleEndian.Uint32(b[56:60])
+	h.Attributes[2] = binary.LittleEndian.Uint32(b[60:64])
+	h.Ldc = binary.LittleEndian.Uint32(b[64:68])
+	h.Gdc = binary.LittleEndian.Uint32(b[68:72])
+	h.TimeStampSec = binary.LittleEndian.Uint32(b[72:76])
+	h.TimeStampMicroSec = binary.LittleEndian.Uint32(b[76:80])
+}
+
+// checkEquipment insures we only have one equipment,
 // as this is the only thing we can deal with so far
-func (dr *DateReader) mustHaveOnlyOneEquipment() {
-	eqSize := binary.LittleEndian.Uint32(dr.event.payload[:4])
-	if eqSize != uint32(dr.event.size) {
-		log.Fatal(eqSize, "!=", uint32(dr.event.size)+headerSize)
+func (event *EventType) checkEquipment() error {
+	if event.size < 4 {
+		return ErrInvalidEquipment
+	}
+	eqSize := binary.LittleEndian.Uint32(event.payload[:4])
+	if eqSize != uint32(event.size) {
+		return ErrInvalidEquipment
+	}
+	return nil
+}
+
+// readEvent reads the next DATE event from r into event, and returns
+// the number of bytes consumed.
+// The payload of the events rejected by the filter is skipped.
+//
+// If no valid event header is found, the bytes up to the next
+// header are skipped. io.EOF is returned only if there was no more
+// event to read.
+func readEvent(r *bufio.Reader, event *EventType, filter *EventFilter) (int64, error) {
+	b, err := r.Peek(int(headerSize))
+	if len(b) == 0 && err == io.EOF {
+		return 0, err
+	}
+	if len(b) != int(headerSize) {
+		n, _ := r.Discard(len(b))
+		return int64(n), io.ErrUnexpectedEOF
+	}
+
+	event.size = 0
+	err = event.fillHeader(b)
+	if err != nil {
+		return skipToHeader(r), err
+	}
+	r.Discard(int(headerSize))
+	consumed := int64(headerSize)
+
+	if event.header.EventSize <= headerSize {
+		// emty event, we skip it
+		return consumed, ErrEmptyEvent
+	}
+
+	ndatabytes := int(event.header.EventSize - headerSize)
+
+	if !filter.Accept(event.header) {
+		n, err := r.Discard(ndatabytes)
+		consumed += int64(n)
+		if err != nil {
+			return consumed, io.ErrUnexpectedEOF
+		}
+		return consumed, ErrSkipped
+	}
+	n, err := io.ReadFull(r, event.payload[:ndatabytes])
+	consumed += int64(n)
+	if err != nil {
+		return consumed, io.ErrUnexpectedEOF
+	}
+
+	event.size = ndatabytes
+
+	return consumed, event.checkEquipment()
+}
+
+// skipToHeader discards the bytes of r up to the next event header,
+// the current position being known not to be a valid one, and
+// returns the number of bytes discarded
+func skipToHeader(r *bufio.Reader) int64 {
+	n, _ := r.Discard(1)
+	skipped := int64(n)
+	for {
+		b, _ := r.Peek(4096)
+		if len(b) < 8 {
+			n, _ = r.Discard(len(b))
+			return skipped + int64(n)
+		}
+		i := findHeader(b)
+		if i >= 0 {
+			n, _ = r.Discard(i)
+			return skipped + int64(n)
+		}
+		// the last 7 bytes could be the start of a header
+		n, _ = r.Discard(len(b) - 7)
+		skipped += int64(n)
 	}
 }
 
 // GetNextEvent gets the next DATE event found
 func (dr *DateReader) GetNextEvent() (err error) {
-	n, err := dr.r.Read(dr.headBuf)
-	if err != nil {
+	var n int64
+	if dr.mapped != nil {
+		n, err = dr.getMappedEvent()
+	} else if dr.prefetch != nil {
+		n, err = dr.getPrefetchedEvent()
+	} else {
+		n, err = readEvent(dr.r, dr.event, &dr.filter)
+	}
+	if err == io.EOF {
 		return err
 	}
-	if n != int(headerSize) {
-		log.Fatalf("Read %d bytes and not %d as expected", n, headerSize)
+	dr.nevents++
+	dr.header = dr.event.header
+	dr.offset += n
+	if err != nil {
+		dr.pos = -1
 	}
+	return err
+}
 
-	dr.nevents++
+// Index returns the index of the events of the file. It is read from
+// the sidecar index file if there is a valid one, or built otherwise.
+func (dr *DateReader) Index() (*Index, error) {
+	if dr.index != nil {
+		return dr.index, nil
+	}
+	idx, err := LoadIndex(dr.name)
+	if err != nil {
+		idx, err = BuildIndex(dr.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dr.index = idx
+	return idx, nil
+}
 
-	dr.mustFillHeader()
-	dr.event.OnlyHeader(dr.header)
+// SetIndex sets the index used to seek within the file
+func (dr *DateReader) SetIndex(idx *Index) {
+	dr.index = idx
+}
 
-	if dr.header.EventSize <= headerSize {
-		// emty event, we skip it
-		return ErrEmptyEvent
+// SeekEvent positions the reader so that the next event read
+// is the event number n (starting at zero) of the file
+func (dr *DateReader) SeekEvent(n int) error {
+	idx, err := dr.Index()
+	if err != nil {
+		return err
 	}
+	if n < 0 || n >= idx.Len() {
+		return ErrEventNotFound
+	}
+	return dr.seek(idx.Entries[n].Offset)
+}
 
-	ndatabytes := int(dr.header.EventSize - headerSize)
-	n, err = io.ReadFull(dr.r, dr.event.payload[:ndatabytes])
-
-	if n != ndatabytes {
-		log.Println(err)
-		log.Fatalf("Could only read %d out of %d bytes expected", n, ndatabytes)
+// SeekEventID positions the reader so that the next event read
+// is the first one with the given id
+func (dr *DateReader) SeekEventID(id EventID) error {
+	idx, err := dr.Index()
+	if err != nil {
+		return err
 	}
+	n, err := idx.Find(id)
 	if err != nil {
-		dr.pos = -1
 		return err
 	}
+	return dr.seek(idx.Entries[n].Offset)
+}
 
-	dr.event.size = ndatabytes
+// SeekTime positions the reader so that the next event read
+// is the first one recorded at or after t
+func (dr *DateReader) SeekTime(t time.Time) error {
+	idx, err := dr.Index()
+	if err != nil {
+		return err
+	}
+	for _, e := range idx.Entries {
+		if !e.Time().Before(t) {
+			return dr.seek(e.Offset)
+		}
+	}
+	return ErrEventNotFound
+}
 
-	dr.mustHaveOnlyOneEquipment()
+// Offset returns the position in the file of the next event to be read
+func (dr *DateReader) Offset() int64 {
+	return dr.offset
+}
 
+func (dr *DateReader) seek(offset int64) error {
+	if dr.file == nil {
+		return ErrNotSeekable
+	}
+	if dr.mapped != nil {
+		dr.offset = offset
+		dr.pos = -1
+		return nil
+	}
+	n := dr.stopPrefetch()
+	_, err := dr.file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	dr.r.Reset(dr.file)
+	dr.offset = offset
+	dr.pos = -1
+	dr.Prefetch(n)
 	return nil
 }
 
@@ -237,7 +555,7 @@ func (dr *DateReader) String() string {
 	v += fmt.Sprintf("Read %d events", dr.NofEvents())
 	v += fmt.Sprintf(" and %d GBT words. Pos %d\n", dr.ngbt, dr.pos)
 	v += fmt.Sprintf("Last known event is :")
-	v += fmt.Sprintf(dr.event.String())
+	v += dr.event.String()
 	return v
 }
 
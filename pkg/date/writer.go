@@ -0,0 +1,139 @@
+package date
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// currentVersion is the DATE header version written by Writer
+const currentVersion uint32 = 0x00030014
+
+// Writer writes DATE events made of a single equipment holding a
+// start of packet word, GBT words and an end of packet word, i.e.
+// the events DateReader reads
+type Writer struct {
+	w       *bufio.Writer
+	closer  io.Closer
+	layout  Layout
+	buf     []byte
+	nevents int
+}
+
+// NewWriter returns a Writer of DATE events to w, using the default layout
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), layout: DefaultLayout, buf: make([]byte, headerSize)}
+}
+
+// Create creates the given file and returns a Writer of DATE events to it
+func Create(filename string) (*Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	dw := NewWriter(file)
+	dw.closer = file
+	return dw, nil
+}
+
+// SetLayout sets the layout of the GBT words within the DATE quartets
+func (dw *Writer) SetLayout(l Layout) {
+	dw.layout = l
+}
+
+// WriteEvent writes an event with the given header and GBT words.
+// The size, magic and header size fields of h are computed.
+// An event without GBT words is written without equipment, like
+// the run records.
+func (dw *Writer) WriteEvent(h EventHeaderType, words [][]byte) error {
+	var ndatabytes int
+	if len(words) > 0 {
+		ndatabytes = equipmentHeaderSize + (len(words)+2)*nDateBytesPerGBT
+	}
+	h.EventSize = headerSize + uint32(ndatabytes)
+	h.EventMagic = magic
+	h.HeaderSize = headerSize
+	if h.Version == 0 {
+		h.Version = currentVersion
+	}
+	encodeHeader(dw.buf[:headerSize], &h)
+	if _, err := dw.w.Write(dw.buf[:headerSize]); err != nil {
+		return err
+	}
+	if ndatabytes == 0 {
+		dw.nevents++
+		return nil
+	}
+	if len(dw.buf) < ndatabytes {
+		dw.buf = make([]byte, ndatabytes)
+	}
+	b := dw.buf[:ndatabytes]
+	for i := range b {
+		b[i] = 0
+	}
+	binary.LittleEndian.PutUint32(b[0:4], uint32(ndatabytes))
+	sop := b[equipmentHeaderSize:]
+	binary.LittleEndian.PutUint32(sop[12:16], 1)
+	for i, w := range words {
+		if len(w) != 10 {
+			return errors.New(fmt.Sprintf("date: GBT word %d is %d bytes long instead of 10", i, len(w)))
+		}
+		q := sop[(i+1)*nDateBytesPerGBT:]
+		for j, k := range dw.layout.Bytes {
+			q[k] = w[j]
+		}
+	}
+	// the end of packet quartet is left all zeros, i.e. with a zero status
+	if _, err := dw.w.Write(b); err != nil {
+		return err
+	}
+	dw.nevents++
+	return nil
+}
+
+// NofEvents returns the number of events written so far
+func (dw *Writer) NofEvents() int {
+	return dw.nevents
+}
+
+// Flush writes the buffered events to the underlying writer
+func (dw *Writer) Flush() error {
+	return dw.w.Flush()
+}
+
+// Close flushes the events and closes the underlying file,
+// if the writer was created by Create
+func (dw *Writer) Close() error {
+	err := dw.Flush()
+	if dw.closer == nil {
+		return err
+	}
+	if cerr := dw.closer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// encodeHeader is the inverse of decodeHeader
+func encodeHeader(b []byte, h *EventHeaderType) {
+	binary.LittleEndian.PutUint32(b[:4], h.EventSize)
+	binary.LittleEndian.PutUint32(b[4:8], h.EventMagic)
+	binary.LittleEndian.PutUint32(b[8:12], h.HeaderSize)
+	binary.LittleEndian.PutUint32(b[12:16], h.Version)
+	binary.LittleEndian.PutUint32(b[16:20], uint32(h.EventType))
+	binary.LittleEndian.PutUint32(b[20:24], h.RunNumber)
+	binary.LittleEndian.PutUint64(b[24:32], uint64(h.EventID))
+	binary.LittleEndian.PutUint64(b[32:40], h.Trigger[0])
+	binary.LittleEndian.PutUint64(b[40:48], h.Trigger[1])
+	binary.LittleEndian.PutUint32(b[48:52], h.Detectors)
+	binary.LittleEndian.PutUint32(b[52:56], h.Attributes[0])
+	binary.LittleEndian.PutUint32(b[56:60], h.Attributes[1])
+	binary.LittleEndian.PutUint32(b[60:64], h.Attributes[2])
+	binary.LittleEndian.PutUint32(b[64:68], h.Ldc)
+	binary.LittleEndian.PutUint32(b[68:72], h.Gdc)
+	binary.LittleEndian.PutUint32(b[72:76], h.TimeStampSec)
+	binary.LittleEndian.PutUint32(b[76:80], h.TimeStampMicroSec)
+}
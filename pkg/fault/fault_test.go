@@ -0,0 +1,65 @@
+package fault
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+)
+
+var trials = flag.Int("fault.trials", 20, "number of trials per kind of fault (e.g. 10000 for a long run)")
+
+func TestFaults(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, k := range Kinds() {
+		res, err := Run(k, *trials, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(res)
+		if res.Crashes > 0 {
+			t.Errorf("%v : %d crashes, first one : %s", k, res.Crashes, res.Panics[0])
+		}
+		if k == TruncateEvent && res.Recovered == 0 {
+			t.Errorf("%v : expected the decoder to recover", k)
+		}
+	}
+}
+
+func TestNoFault(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	inj := NewInjector(1)
+	tr, err := newTrial(inj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := tr.date(tr.words[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.detected {
+		t.Errorf("Expected no error on a clean stream")
+	}
+	for _, h := range tr.headers {
+		if !samePackets(tr.expected[h.EventID], o.packets[h.EventID]) {
+			t.Errorf("Event %v : expected %d packets got %d", h.EventID, len(tr.expected[h.EventID]), len(o.packets[h.EventID]))
+		}
+	}
+}
+
+func TestSlipBit(t *testing.T) {
+	words := [][]byte{make([]byte, 10), make([]byte, 10)}
+	for i := 0; i < 4; i++ {
+		setBit(words, 5, i, i%2 == 0)
+	}
+	if words[0][1] != 0x08 || words[1][1] != 0x08 {
+		t.Errorf("Expected bits 3 of byte 1 set got %v", words)
+	}
+}
@@ -0,0 +1,147 @@
+// Package fault corrupts clean GBT or DATE streams and measures how
+// well the decoder detects the errors, corrects them, and recovers
+// from them.
+package fault
+
+import (
+	"encoding/binary"
+	"math/rand"
+)
+
+// Kind is a kind of fault
+type Kind int
+
+const (
+	BitFlip Kind = iota
+	BurstFlip
+	DropWord
+	DuplicateWord
+	TruncateEvent
+	BitSlip
+)
+
+var kindNames = []string{"bit-flip", "burst-flip", "drop-word", "duplicate-word", "truncate-event", "bit-slip"}
+
+// Kinds returns all the kinds of faults
+func Kinds() []Kind {
+	return []Kind{BitFlip, BurstFlip, DropWord, DuplicateWord, TruncateEvent, BitSlip}
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// ParseKind returns the kind of fault with the given name
+func ParseKind(s string) (Kind, bool) {
+	for i, n := range kindNames {
+		if n == s {
+			return Kind(i), true
+		}
+	}
+	return 0, false
+}
+
+// Injector applies random faults. Given the same seed, it
+// applies the same faults.
+type Injector struct {
+	rnd *rand.Rand
+	// BurstLength is the maximum number of consecutive bits
+	// flipped by FlipBurst
+	BurstLength int
+}
+
+// NewInjector returns an injector using the given random seed
+func NewInjector(seed int64) *Injector {
+	return &Injector{rnd: rand.New(rand.NewSource(seed)), BurstLength: 16}
+}
+
+// Intn returns a random number in [0,n)
+func (inj *Injector) Intn(n int) int {
+	return inj.rnd.Intn(n)
+}
+
+// FlipBit flips one random bit of the GBT words
+func (inj *Injector) FlipBit(words [][]byte) {
+	w := inj.rnd.Intn(len(words))
+	bit := uint(inj.rnd.Intn(80))
+	words[w][bit/8] ^= 1 << (bit % 8)
+}
+
+// FlipBurst flips from 2 to BurstLength consecutive bits of the GBT
+// words, i.e. bits of the successive elinks of one word, possibly
+// continuing into the next words
+func (inj *Injector) FlipBurst(words [][]byte) {
+	n := 2 + inj.rnd.Intn(inj.BurstLength-1)
+	pos := inj.rnd.Intn(len(words) * 80)
+	for i := 0; i < n && pos+i < len(words)*80; i++ {
+		w := (pos + i) / 80
+		bit := uint((pos + i) % 80)
+		words[w][bit/8] ^= 1 << (bit % 8)
+	}
+}
+
+// DropWord removes one random GBT word
+func (inj *Injector) DropWord(words [][]byte) [][]byte {
+	w := inj.rnd.Intn(len(words))
+	return append(words[:w:w], words[w+1:]...)
+}
+
+// DuplicateWord repeats one random GBT word
+func (inj *Injector) DuplicateWord(words [][]byte) [][]byte {
+	w := inj.rnd.Intn(len(words))
+	dup := append([]byte(nil), words[w]...)
+	out := append(words[:w+1:w+1], dup)
+	return append(out, words[w+1:]...)
+}
+
+// SlipBit removes one random bit of the stream of one random elink :
+// the following bits of that elink arrive one bit earlier, and the
+// elink gets a zero in the last word
+func (inj *Injector) SlipBit(words [][]byte) {
+	elink := inj.rnd.Intn(40)
+	n := 2 * len(words)
+	pos := inj.rnd.Intn(n)
+	for i := pos; i < n-1; i++ {
+		setBit(words, elink, i, getBit(words, elink, i+1))
+	}
+	setBit(words, elink, n-1, false)
+}
+
+// elinkBit returns the location of bit pos of the stream of the
+// elink, with the bit order of sampa.Dispatch
+func elinkBit(elink, pos int) (word, byteIndex int, bit uint) {
+	word = pos / 2
+	byteIndex = elink / 4
+	bit = uint(elink%4) * 2
+	if pos%2 == 0 {
+		bit++
+	}
+	return word, byteIndex, bit
+}
+
+func getBit(words [][]byte, elink, pos int) bool {
+	w, b, bit := elinkBit(elink, pos)
+	return words[w][b]&(1<<bit) != 0
+}
+
+func setBit(words [][]byte, elink, pos int, v bool) {
+	w, b, bit := elinkBit(elink, pos)
+	if v {
+		words[w][b] |= 1 << bit
+	} else {
+		words[w][b] &^= 1 << bit
+	}
+}
+
+// TruncateEvent removes the last bytes of the DATE event starting at
+// offset in data, without fixing any size, as would a readout
+// losing the end of an event
+func (inj *Injector) TruncateEvent(data []byte, offset int) []byte {
+	size := int(binary.LittleEndian.Uint32(data[offset : offset+4]))
+	n := 1 + inj.rnd.Intn(size-1)
+	end := offset + size
+	return append(data[:end-n:end-n], data[end:]...)
+}
@@ -0,0 +1,276 @@
+package fault
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+	"github.com/mrrtf/sampa/pkg/sim"
+)
+
+// Result summarizes the trials of one kind of fault
+type Result struct {
+	Kind       Kind
+	Trials     int
+	Detected   int // the decoder reported an error or a correction
+	Corrected  int // an error or a correction was reported and the decoded packets are the same as without the fault
+	Unaffected int // no error was reported and the decoded packets are the same as without the fault
+	Recovered  int // the packets of the last event (after the corrupted one) are all decoded
+	Undetected int // the decoded packets are wrong and no error was reported
+	Aborted    int // the reader returned an error it cannot continue after
+	Crashes    int // the decoder panicked
+	Panics     []string
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%-15s %6d trials : detected %5.1f%% corrected %5.1f%% unaffected %5.1f%% recovered %5.1f%% undetected %5.1f%% aborted %d crashes %d",
+		r.Kind, r.Trials, rate(r.Detected, r.Trials), rate(r.Corrected, r.Trials), rate(r.Unaffected, r.Trials),
+		rate(r.Recovered, r.Trials), rate(r.Undetected, r.Trials), r.Aborted, r.Crashes)
+}
+
+// nEvents is the number of events of a trial
+const nEvents = 4
+
+// trial is a clean stream of DATE events : the second one is to be
+// corrupted, the following ones start with syncs, and the last one
+// is used to check the decoder recovers
+type trial struct {
+	words    [nEvents][][]byte
+	headers  [nEvents]date.EventHeaderType
+	expected map[date.EventID][]string
+}
+
+// outcome is what the decoder made of a stream
+type outcome struct {
+	packets  map[date.EventID][]string
+	detected bool
+	aborted  bool
+}
+
+// newTrial simulates random packets on random elinks
+func newTrial(inj *Injector) (*trial, error) {
+	t := &trial{expected: make(map[date.EventID][]string)}
+	s := sim.New()
+	for ev := 0; ev < nEvents; ev++ {
+		if ev > 1 {
+			s.Encoder().SyncAll()
+		}
+		h := date.EventHeaderType{EventType: date.PhysicsEvent, EventID: date.NewEventID(1, uint32(ev+1), 0)}
+		e := sim.Event{Header: h}
+		for elink := 0; elink < sampa.NofELinks; elink++ {
+			if inj.Intn(3) == 0 {
+				continue
+			}
+			var clusters []sampa.Cluster
+			for c := 1 + inj.Intn(3); c > 0; c-- {
+				samples := make([]int, 1+inj.Intn(10))
+				for i := range samples {
+					samples[i] = inj.Intn(1024)
+				}
+				clusters = append(clusters, sampa.NewCluster(inj.Intn(1024), samples))
+			}
+			p, err := sampa.NewDataPacket(elink, uint(inj.Intn(16)), uint(inj.Intn(32)), uint32(inj.Intn(1<<20)), clusters)
+			if err != nil {
+				return nil, err
+			}
+			e.Packets = append(e.Packets, p)
+			t.expected[h.EventID] = append(t.expected[h.EventID], packetKey(p))
+		}
+		words, err := s.Encode(e)
+		if err != nil {
+			return nil, err
+		}
+		t.words[ev] = words
+		t.headers[ev] = h
+	}
+	return t, nil
+}
+
+// date returns the trial as DATE events, with the given
+// GBT words for the second event
+func (t *trial) date(corrupted [][]byte) ([]byte, int, error) {
+	var buf bytes.Buffer
+	w := date.NewWriter(&buf)
+	offset := 0
+	for ev := 0; ev < nEvents; ev++ {
+		words := t.words[ev]
+		if ev == 1 {
+			w.Flush()
+			offset = buf.Len()
+			words = corrupted
+		}
+		if err := w.WriteEvent(t.headers[ev], words); err != nil {
+			return nil, 0, err
+		}
+	}
+	err := w.Flush()
+	return buf.Bytes(), offset, err
+}
+
+// decode runs the whole date, Dispatch and elink chain.
+// The returned error is only set if the decoder panicked.
+func decode(data []byte) (o outcome, err error) {
+	o.packets = make(map[date.EventID][]string)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	r := date.NewReaderFrom(bytes.NewReader(data))
+	elinks := make([]sampa.ELink, sampa.NofELinks)
+	for i := range elinks {
+		elinks[i] = sampa.NewELink(i)
+		if c, ok := elinks[i].(sampa.Decoder); ok {
+			c.CheckHeaders(true)
+		}
+	}
+	ten := make([]byte, 10)
+	for {
+		_, err := r.Read(ten)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			if err != date.ErrEndOfEvent {
+				o.detected = true
+			}
+			if r.IsDiscontinuity(err) {
+				resetELinks(elinks)
+			}
+			if date.IsEndOfEvent(err) {
+				continue
+			}
+			o.aborted = true
+			break
+		}
+		id := r.Header().EventID
+		err = sampa.DispatchFunc(ten, elinks, 0, func(p *sampa.Packet) {
+			o.packets[id] = append(o.packets[id], packetKey(p))
+		})
+		if err != nil {
+			o.detected = true
+		}
+	}
+	if r.NofMissingEOP() > 0 || r.NofInvalidEOP() > 0 {
+		o.detected = true
+	}
+	for _, e := range elinks {
+		if c, ok := e.(sampa.Decoder); ok && c.NofCorrectedHeaders() > 0 {
+			o.detected = true
+		}
+	}
+	return o, nil
+}
+
+// resetELinks makes the elinks look for a sync again
+func resetELinks(elinks []sampa.ELink) {
+	for _, e := range elinks {
+		if d, ok := e.(sampa.Decoder); ok {
+			d.Reset()
+		}
+	}
+}
+
+// packetKey identifies a packet by its elink, its whole header
+// (packet type, addresses, bunch crossing, ...) and its clusters
+// (timestamps and samples, in order)
+func packetKey(p *sampa.Packet) string {
+	key := fmt.Sprintf("%d %013x", p.ELink(), p.Header().Uint64(0, -1))
+	for _, c := range p.Clusters() {
+		key += " | " + c.String()
+	}
+	return key
+}
+
+func samePackets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int)
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+		if count[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Run makes n trials of the given kind of fault
+func Run(kind Kind, n int, seed int64) (Result, error) {
+	res := Result{Kind: kind}
+	inj := NewInjector(seed)
+	for i := 0; i < n; i++ {
+		t, err := newTrial(inj)
+		if err != nil {
+			return res, err
+		}
+		words := make([][]byte, len(t.words[1]))
+		for j, w := range t.words[1] {
+			words[j] = append([]byte(nil), w...)
+		}
+		switch kind {
+		case BitFlip:
+			inj.FlipBit(words)
+		case BurstFlip:
+			inj.FlipBurst(words)
+		case DropWord:
+			words = inj.DropWord(words)
+		case DuplicateWord:
+			words = inj.DuplicateWord(words)
+		case BitSlip:
+			inj.SlipBit(words)
+		}
+		data, offset, err := t.date(words)
+		if err != nil {
+			return res, err
+		}
+		if kind == TruncateEvent {
+			data = inj.TruncateEvent(data, offset)
+		}
+		res.Trials++
+		o, err := decode(data)
+		if err != nil {
+			res.Crashes++
+			res.Panics = append(res.Panics, err.Error())
+			continue
+		}
+		if o.aborted {
+			res.Aborted++
+		}
+		correct := true
+		for ev, h := range t.headers {
+			same := samePackets(t.expected[h.EventID], o.packets[h.EventID])
+			if !same {
+				correct = false
+			}
+			if ev == nEvents-1 && same {
+				res.Recovered++
+			}
+		}
+		if o.detected {
+			res.Detected++
+		}
+		switch {
+		case correct && o.detected:
+			res.Corrected++
+		case correct:
+			res.Unaffected++
+		case !o.detected:
+			res.Undetected++
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,279 @@
+package fit
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+// Result holds the outcome of a fit
+type Result struct {
+	Amplitude  float64 `json:"amplitude"`
+	Time       float64 `json:"time"` // time of arrival (t0)
+	Baseline   float64 `json:"baseline"`
+	Tau        float64 `json:"tau"`
+	Chi2       float64 `json:"chi2"`
+	NDF        int     `json:"ndf"`
+	Converged  bool    `json:"converged"`
+	Iterations int     `json:"iterations"`
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("A=%.2f t0=%.3f B=%.2f tau=%.3f chi2/ndf=%.2f/%d converged=%v (%d iterations)",
+		r.Amplitude, r.Time, r.Baseline, r.Tau, r.Chi2, r.NDF, r.Converged, r.Iterations)
+}
+
+// Fitter fits the shaper function to cluster samples.
+//
+// In Fast mode the peaking time is fixed to Tau and, the model being
+// linear in amplitude and baseline, only the time of arrival is searched
+// for (amplitude and baseline are solved exactly for each trial time).
+// Otherwise the fast result is refined by a Levenberg-Marquardt fit
+// of all four parameters, including the peaking time.
+type Fitter struct {
+	Tau           float64 // (initial) peaking time
+	Sigma         float64 // noise of each sample, used for the chi2
+	Fast          bool
+	MaxIterations int
+}
+
+// NewFitter returns a full fitter with default settings
+func NewFitter() *Fitter {
+	return &Fitter{Tau: DefaultTau, Sigma: 1, MaxIterations: 100}
+}
+
+// Fit fits the cluster samples. Times in the result are absolute,
+// i.e. include the cluster timestamp.
+func (f *Fitter) Fit(c sampa.Cluster) Result {
+	y := make([]float64, len(c.Samples()))
+	for i, s := range c.Samples() {
+		y[i] = float64(s)
+	}
+	r := f.FitSamples(y)
+	r.Time += float64(c.Timestamp())
+	return r
+}
+
+// FitSamples fits samples taken at times 0,1,2...
+func (f *Fitter) FitSamples(y []float64) Result {
+	r := f.fitFast(y)
+	if f.Fast || !r.Converged || len(y) <= 4 {
+		return r
+	}
+	return f.fitFull(y, r)
+}
+
+func (f *Fitter) chi2(y []float64, p [4]float64) float64 {
+	chi2 := 0.0
+	for i, v := range y {
+		d := (v - Shape(float64(i), p[0], p[1], p[2], p[3])) / f.Sigma
+		chi2 += d * d
+	}
+	return chi2
+}
+
+// linear returns the amplitude and baseline minimizing the chi2
+// for the given time of arrival and peaking time
+func linear(y []float64, t0, tau float64) (float64, float64, bool) {
+	var sg, sgg, sy, sgy float64
+	for i, v := range y {
+		g := Gamma4((float64(i) - t0) / tau)
+		sg += g
+		sgg += g * g
+		sy += v
+		sgy += g * v
+	}
+	n := float64(len(y))
+	det := n*sgg - sg*sg
+	if math.Abs(det) < 1e-12 {
+		return 0, 0, false
+	}
+	a := (n*sgy - sg*sy) / det
+	b := (sgg*sy - sg*sgy) / det
+	return a, b, true
+}
+
+// edge is the distance to the edges of the search window below
+// which the minimum is considered to be at an edge
+const edge = 1e-3
+
+// scanStep is the step of the scan of the time of arrival done
+// when the minimum is not found around the expected position
+const scanStep = 0.5
+
+// fitFast searches for the time of arrival starting from the
+// position expected from the largest sample
+func (f *Fitter) fitFast(y []float64) Result {
+	imax := 0
+	for i, v := range y {
+		if v > y[imax] {
+			imax = i
+		}
+	}
+	return f.fitFastFrom(y, float64(imax)-f.Tau)
+}
+
+// fitFastFrom does a golden section search of the time of arrival in
+// a window of 2 sampling periods around guess. If the minimum is found
+// at an edge of the window, or has a negative amplitude, the time of arrival is first scanned, by
+// steps of scanStep, from -2*Tau to the last sample, and the search is
+// done again around the best time of the scan. The result is not
+// converged if the minimum is still at an edge.
+func (f *Fitter) fitFastFrom(y []float64, guess float64) Result {
+	r := Result{Tau: f.Tau, NDF: len(y) - 3}
+	if len(y) < 3 {
+		return r
+	}
+	eval := func(t0 float64) float64 {
+		a, b, ok := linear(y, t0, f.Tau)
+		if !ok {
+			return math.Inf(1)
+		}
+		return f.chi2(y, [4]float64{a, t0, b, f.Tau})
+	}
+	lo, hi := guess-1, guess+1
+	t0, n, converged := golden(eval, lo, hi, f.MaxIterations)
+	r.Iterations = n
+	atEdge := t0-lo < edge || hi-t0 < edge
+	if a, _, _ := linear(y, t0, f.Tau); atEdge || a <= 0 {
+		best, chi2 := guess, math.Inf(1)
+		for t := -2 * f.Tau; t <= float64(len(y)-1); t += scanStep {
+			if c := eval(t); c < chi2 {
+				best, chi2 = t, c
+			}
+		}
+		lo, hi = best-scanStep, best+scanStep
+		t0, n, converged = golden(eval, lo, hi, f.MaxIterations)
+		r.Iterations += n
+		atEdge = t0-lo < edge || hi-t0 < edge
+	}
+	a, b, ok := linear(y, t0, f.Tau)
+	r.Amplitude, r.Time, r.Baseline = a, t0, b
+	r.Chi2 = f.chi2(y, [4]float64{a, t0, b, f.Tau})
+	r.Converged = ok && converged && !atEdge && a > 0 && !math.IsInf(r.Chi2, 0)
+	return r
+}
+
+// golden returns the minimum of eval in [lo,hi] found by a golden
+// section search, the number of iterations, and whether the search
+// converged within maxIterations
+func golden(eval func(float64) float64, lo, hi float64, maxIterations int) (float64, int, bool) {
+	const invphi = 0.6180339887498949
+	x1 := hi - invphi*(hi-lo)
+	x2 := lo + invphi*(hi-lo)
+	f1, f2 := eval(x1), eval(x2)
+	it := 0
+	for ; hi-lo > 1e-4 && it < maxIterations; it++ {
+		if f1 < f2 {
+			hi, x2, f2 = x2, x1, f1
+			x1 = hi - invphi*(hi-lo)
+			f1 = eval(x1)
+		} else {
+			lo, x1, f1 = x1, x2, f2
+			x2 = lo + invphi*(hi-lo)
+			f2 = eval(x2)
+		}
+	}
+	return (lo + hi) / 2, it, hi-lo <= 1e-4
+}
+
+// fitFull runs a Levenberg-Marquardt minimization of
+// (amplitude, t0, baseline, tau) starting from r
+func (f *Fitter) fitFull(y []float64, r Result) Result {
+	p := [4]float64{r.Amplitude, r.Time, r.Baseline, r.Tau}
+	chi2 := f.chi2(y, p)
+	lambda := 1e-3
+	converged := false
+	it := 0
+	for ; it < f.MaxIterations && !converged; it++ {
+		var jtj [4][4]float64
+		var jtr [4]float64
+		for i, v := range y {
+			x := (float64(i) - p[1]) / p[3]
+			dg := dGamma4(x)
+			j := [4]float64{Gamma4(x), -p[0] * dg / p[3], 1, -p[0] * dg * x / p[3]}
+			res := v - Shape(float64(i), p[0], p[1], p[2], p[3])
+			for k := 0; k < 4; k++ {
+				jtr[k] += j[k] * res
+				for l := 0; l < 4; l++ {
+					jtj[k][l] += j[k] * j[l]
+				}
+			}
+		}
+		for {
+			m := jtj
+			for k := 0; k < 4; k++ {
+				m[k][k] *= 1 + lambda
+			}
+			delta, ok := solve(m, jtr)
+			if !ok {
+				return r
+			}
+			q := p
+			for k := range q {
+				q[k] += delta[k]
+			}
+			c := math.Inf(1)
+			if q[3] > 0 {
+				c = f.chi2(y, q)
+			}
+			if c <= chi2 {
+				converged = chi2-c < 1e-6*(chi2+1e-9)
+				p, chi2 = q, c
+				lambda /= 10
+				break
+			}
+			lambda *= 10
+			if lambda > 1e10 {
+				// no step improves : we are at the minimum
+				converged = true
+				break
+			}
+		}
+	}
+	return Result{
+		Amplitude:  p[0],
+		Time:       p[1],
+		Baseline:   p[2],
+		Tau:        p[3],
+		Chi2:       chi2,
+		NDF:        len(y) - 4,
+		Converged:  converged && p[0] > 0,
+		Iterations: r.Iterations + it,
+	}
+}
+
+// solve solves m.x = v by Gaussian elimination with partial pivoting
+func solve(m [4][4]float64, v [4]float64) ([4]float64, bool) {
+	const n = 4
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-15 {
+			return v, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		v[col], v[pivot] = v[pivot], v[col]
+		for row := col + 1; row < n; row++ {
+			r := m[row][col] / m[col][col]
+			for k := col; k < n; k++ {
+				m[row][k] -= r * m[col][k]
+			}
+			v[row] -= r * v[col]
+		}
+	}
+	var x [4]float64
+	for row := n - 1; row >= 0; row-- {
+		s := v[row]
+		for k := row + 1; k < n; k++ {
+			s -= m[row][k] * x[k]
+		}
+		x[row] = s / m[row][row]
+	}
+	return x, true
+}
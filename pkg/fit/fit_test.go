@@ -0,0 +1,112 @@
+package fit
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func pulse(n int, a, t0, b, tau float64) []float64 {
+	y := make([]float64, n)
+	for i := range y {
+		y[i] = Shape(float64(i), a, t0, b, tau)
+	}
+	return y
+}
+
+func TestFitExact(t *testing.T) {
+	y := pulse(12, 500, 2.3, 50, DefaultTau)
+	for _, fast := range []bool{true, false} {
+		f := NewFitter()
+		f.Fast = fast
+		r := f.FitSamples(y)
+		if !r.Converged {
+			t.Errorf("fast=%v : expected convergence got %v", fast, r)
+		}
+		if math.Abs(r.Amplitude-500) > 0.1 || math.Abs(r.Time-2.3) > 1e-3 || math.Abs(r.Baseline-50) > 0.1 {
+			t.Errorf("fast=%v : expected A=500 t0=2.3 B=50 got %v", fast, r)
+		}
+	}
+}
+
+func TestFitFastOutsideWindow(t *testing.T) {
+	y := pulse(20, 500, 6.4, 50, DefaultTau)
+	f := NewFitter()
+	for _, guess := range []float64{0, 3.5, 9, 100} {
+		r := f.fitFastFrom(y, guess)
+		if !r.Converged || math.Abs(r.Time-6.4) > 1e-3 {
+			t.Errorf("guess %v : expected t0=6.4 got %v", guess, r)
+		}
+	}
+	// only the tail of a pulse starting before the scanned times
+	y = pulse(20, 5000, -8, 50, DefaultTau)
+	if r := f.fitFastFrom(y, 0); r.Converged {
+		t.Errorf("Expected no convergence with the minimum at an edge got %v", r)
+	}
+}
+
+func TestFitTau(t *testing.T) {
+	y := pulse(15, 300, 3.6, 10, 2.8)
+	f := NewFitter()
+	r := f.FitSamples(y)
+	if !r.Converged || math.Abs(r.Tau-2.8) > 1e-2 || math.Abs(r.Time-3.6) > 1e-2 {
+		t.Errorf("Expected tau=2.8 t0=3.6 got %v", r)
+	}
+	f.Fast = true
+	if r := f.FitSamples(y); r.Chi2 < 1 {
+		t.Errorf("Expected a bad chi2 with fixed tau got %v", r)
+	}
+}
+
+func TestFitNoise(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	f := NewFitter()
+	f.Sigma = 2
+	y := pulse(12, 200, 1.7, 100, DefaultTau)
+	for i := range y {
+		y[i] = math.Floor(y[i] + rnd.NormFloat64()*f.Sigma + 0.5)
+	}
+	r := f.FitSamples(y)
+	if !r.Converged || math.Abs(r.Amplitude-200) > 5 || math.Abs(r.Time-1.7) > 0.1 {
+		t.Errorf("Expected A~200 t0~1.7 got %v", r)
+	}
+	if r.Chi2/float64(r.NDF) > 3 {
+		t.Errorf("Expected a reasonable chi2 got %v", r)
+	}
+}
+
+func TestFitCluster(t *testing.T) {
+	y := pulse(10, 100, 1.5, 0, DefaultTau)
+	samples := make([]int, len(y))
+	for i, v := range y {
+		samples[i] = int(math.Floor(v + 0.5))
+	}
+	f := NewFitter()
+	f.Fast = true
+	r := f.Fit(sampa.NewCluster(1000, samples))
+	if math.Abs(r.Time-1001.5) > 0.1 {
+		t.Errorf("Expected absolute time 1001.5 got %v", r.Time)
+	}
+	if r := f.Fit(sampa.NewCluster(0, []int{1, 2})); r.Converged {
+		t.Errorf("Expected no convergence with 2 samples got %v", r)
+	}
+}
+
+func BenchmarkFitFast(b *testing.B) {
+	y := pulse(12, 500, 2.3, 50, DefaultTau)
+	f := NewFitter()
+	f.Fast = true
+	for i := 0; i < b.N; i++ {
+		f.FitSamples(y)
+	}
+}
+
+func BenchmarkFitFull(b *testing.B) {
+	y := pulse(12, 500, 2.3, 50, DefaultTau)
+	f := NewFitter()
+	for i := 0; i < b.N; i++ {
+		f.FitSamples(y)
+	}
+}
@@ -0,0 +1,38 @@
+// Package fit fits the SAMPA shaper response to the samples of a cluster.
+//
+// The shaper response is modelled by a semi-Gaussian of order 4
+//
+//	f(t) = B + A * x^4 * exp(4*(1-x)),  x = (t-t0)/tau,  t > t0
+//	f(t) = B                                              t <= t0
+//
+// where A is the amplitude above baseline B, t0 the time of arrival
+// and tau the peaking time (the pulse peaks at t0+tau).
+// All times are in units of the sampling period.
+package fit
+
+import "math"
+
+// DefaultTau is the default peaking time, in sampling periods
+const DefaultTau = 2.0
+
+// Gamma4 returns the normalized (peak=1 at x=1) shape at x=(t-t0)/tau
+func Gamma4(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	x2 := x * x
+	return x2 * x2 * math.Exp(4*(1-x))
+}
+
+// dGamma4 returns the derivative of Gamma4 with respect to x
+func dGamma4(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return 4 * x * x * x * (1 - x) * math.Exp(4*(1-x))
+}
+
+// Shape returns the shaper response at time t
+func Shape(t, amplitude, t0, baseline, tau float64) float64 {
+	return baseline + amplitude*Gamma4((t-t0)/tau)
+}
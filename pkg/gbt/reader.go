@@ -1,14 +1,126 @@
+// Package gbt reads 80-bits GBT words
 package gbt
 
-// // Word is a 80-bits bitset
-// type Word struct {
-// 	bitset.BitSet
-// }
-//
-// func NewWord() *Word {
-// 	return &Word{BitSet: *(bitset.New(80))}
-// }
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// NofBytes is the number of bytes of a GBT word (80 bits)
+	NofBytes = 10
+)
+
+// WordReader is the source of GBT words the sampa decoder consumes :
+// each successful Read fills p, which must be NofBytes long,
+// with the next GBT word.
 //
-// type Reader interface {
-// 	Word() (g Word, err error)
-// }
+// Both this package Reader and date.DateReader are WordReaders.
+type WordReader interface {
+	Read(p []byte) (int, error)
+	NofGBTwords() int
+}
+
+// Reader reads GBT words from a raw stream (i.e. without any
+// DATE envelope), where each word is stored in wordSize bytes.
+// The 10 bytes of the GBT word start at byte offset within the
+// stored word, the other bytes being padding.
+type Reader struct {
+	r        *bufio.Reader
+	closer   io.Closer
+	wordSize int
+	offset   int
+	buf      []byte
+	ngbt     int
+}
+
+// NewReader returns a Reader of the GBT words stored in r
+func NewReader(r io.Reader, wordSize, offset int) (*Reader, error) {
+	if wordSize < NofBytes {
+		return nil, errors.New(fmt.Sprintf("gbt: word size (%d) must be at least %d bytes", wordSize, NofBytes))
+	}
+	if offset < 0 || offset+NofBytes > wordSize {
+		return nil, errors.New(fmt.Sprintf("gbt: offset %d does not fit in a %d bytes word", offset, wordSize))
+	}
+	return &Reader{r: bufio.NewReader(r), wordSize: wordSize, offset: offset, buf: make([]byte, wordSize)}, nil
+}
+
+// Open returns a Reader of the GBT words stored in the given file
+func Open(filename string, wordSize, offset int) (*Reader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	gr, err := NewReader(file, wordSize, offset)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	gr.closer = file
+	return gr, nil
+}
+
+// Read fills p with the next GBT word.
+// io.ErrUnexpectedEOF is returned if the stream ends in the middle of a word.
+func (gr *Reader) Read(p []byte) (int, error) {
+	if len(p) != NofBytes {
+		return 0, errors.New(fmt.Sprintf("gbt: Read expects a %d bytes slice", NofBytes))
+	}
+	_, err := io.ReadFull(gr.r, gr.buf)
+	if err != nil {
+		return 0, err
+	}
+	copy(p, gr.buf[gr.offset:gr.offset+NofBytes])
+	gr.ngbt++
+	return NofBytes, nil
+}
+
+// NofGBTwords returns the number of GBT words read so far
+func (gr *Reader) NofGBTwords() int {
+	return gr.ngbt
+}
+
+// Close closes the underlying file, if the reader was created by Open
+func (gr *Reader) Close() error {
+	if gr.closer == nil {
+		return nil
+	}
+	return gr.closer.Close()
+}
+
+// DetectWordSize guesses from the first bytes of a raw stream whether
+// the GBT words are stored in 16 bytes, the 10 bytes of the word starting
+// at offset and the other 6 bytes being (zero) padding, or packed in 10 bytes.
+// Leading all-zero 16 bytes words (idle words) are skipped as they cannot
+// tell the two formats apart.
+// It returns 16 if there is at least one complete non zero 16 bytes word
+// in b and all the padding bytes of all those words are zero, and 10 otherwise.
+func DetectWordSize(b []byte, offset int) int {
+	if offset < 0 || offset+NofBytes > 16 {
+		return NofBytes
+	}
+	w := 0
+	for ; w+16 <= len(b) && isZero(b[w:w+16]); w += 16 {
+	}
+	if w+16 > len(b) {
+		return NofBytes
+	}
+	for ; w+16 <= len(b); w += 16 {
+		if !isZero(b[w:w+offset]) || !isZero(b[w+offset+NofBytes:w+16]) {
+			return NofBytes
+		}
+	}
+	return 16
+}
+
+func isZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
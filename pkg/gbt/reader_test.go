@@ -0,0 +1,79 @@
+package gbt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// words returns n raw GBT words of wordSize bytes, the 10 GBT bytes
+// starting at offset and the padding being zeros
+func words(n, wordSize, offset int) []byte {
+	b := make([]byte, n*wordSize)
+	for i := 0; i < n; i++ {
+		for j := 0; j < NofBytes; j++ {
+			b[i*wordSize+offset+j] = byte(i*NofBytes + j + 1)
+		}
+	}
+	return b
+}
+
+func TestReader(t *testing.T) {
+	for _, c := range []struct{ wordSize, offset int }{{10, 0}, {16, 0}, {16, 6}, {12, 1}} {
+		r, err := NewReader(bytes.NewReader(words(3, c.wordSize, c.offset)), c.wordSize, c.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := make([]byte, NofBytes)
+		for i := 0; i < 3; i++ {
+			n, err := r.Read(p)
+			if err != nil || n != NofBytes {
+				t.Fatalf("%v : unexpected read of %d bytes, err %v", c, n, err)
+			}
+			if p[0] != byte(i*NofBytes+1) || p[9] != byte(i*NofBytes+10) {
+				t.Errorf("%v : unexpected word %v", c, p)
+			}
+		}
+		if _, err := r.Read(p); err != io.EOF {
+			t.Errorf("%v : expected EOF got %v", c, err)
+		}
+		if r.NofGBTwords() != 3 {
+			t.Errorf("%v : expected 3 words got %d", c, r.NofGBTwords())
+		}
+	}
+	r, _ := NewReader(bytes.NewReader(words(2, 16, 0)[:20]), 16, 0)
+	p := make([]byte, NofBytes)
+	r.Read(p)
+	if _, err := r.Read(p); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected ErrUnexpectedEOF for a truncated word, got %v", err)
+	}
+	if _, err := NewReader(nil, 16, 7); err == nil {
+		t.Errorf("a 10 bytes word cannot start at byte 7 of a 16 bytes word")
+	}
+}
+
+func TestDetectWordSize(t *testing.T) {
+	if DetectWordSize(words(64, 16, 0), 0) != 16 {
+		t.Errorf("should have detected 16 bytes words")
+	}
+	if DetectWordSize(words(100, 10, 0), 0) != 10 {
+		t.Errorf("should have detected 10 bytes words")
+	}
+	if DetectWordSize(words(64, 16, 6), 6) != 16 {
+		t.Errorf("should have detected 16 bytes words at offset 6")
+	}
+	if DetectWordSize(words(64, 16, 6), 0) != 10 {
+		t.Errorf("16 bytes words at offset 6 do not have their padding at the end")
+	}
+	idle := append(make([]byte, 16*32), words(32, 16, 0)...)
+	if DetectWordSize(idle, 0) != 16 {
+		t.Errorf("should have detected 16 bytes words after leading idle words")
+	}
+	idle = append(make([]byte, 10*32), words(32, 10, 0)...)
+	if DetectWordSize(idle, 0) != 10 {
+		t.Errorf("should have detected 10 bytes words after leading idle words")
+	}
+	if DetectWordSize(make([]byte, 16*64), 0) != 10 {
+		t.Errorf("an all zero stream should default to 10 bytes words")
+	}
+}
@@ -0,0 +1,302 @@
+// Package hist provides simple fixed bin histograms in one and two
+// dimensions, for the decoder level quantities (ADC values, cluster
+// sizes, bunch crossing counters, packet sizes, errors...)
+package hist
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrIncompatible = errors.New("hist: histograms have different binnings")
+
+// axis is a fixed bin axis
+type axis struct {
+	N        int
+	Min, Max float64
+}
+
+// bin returns the bin of x, -1 for underflow and N for overflow
+func (a axis) bin(x float64) int {
+	if x < a.Min {
+		return -1
+	}
+	if x >= a.Max {
+		return a.N
+	}
+	i := int(float64(a.N) * (x - a.Min) / (a.Max - a.Min))
+	if i >= a.N {
+		// rounding
+		i = a.N - 1
+	}
+	return i
+}
+
+func (a axis) width() float64 {
+	return (a.Max - a.Min) / float64(a.N)
+}
+
+func (a axis) low(i int) float64 {
+	return a.Min + float64(i)*a.width()
+}
+
+func (a axis) center(i int) float64 {
+	return a.Min + (float64(i)+0.5)*a.width()
+}
+
+// H1 is a one dimensional histogram
+type H1 struct {
+	Name      string
+	x         axis
+	bins      []float64
+	underflow float64
+	overflow  float64
+	entries   int
+}
+
+// NewH1 returns an empty histogram of nbins between min and max
+func NewH1(name string, nbins int, min, max float64) *H1 {
+	if nbins < 1 || max <= min {
+		panic("hist: invalid binning")
+	}
+	return &H1{Name: name, x: axis{nbins, min, max}, bins: make([]float64, nbins)}
+}
+
+// Fill adds one to the bin of x
+func (h *H1) Fill(x float64) {
+	h.FillW(x, 1)
+}
+
+// FillW adds w to the bin of x
+func (h *H1) FillW(x, w float64) {
+	h.entries++
+	switch i := h.x.bin(x); {
+	case i < 0:
+		h.underflow += w
+	case i >= h.x.N:
+		h.overflow += w
+	default:
+		h.bins[i] += w
+	}
+}
+
+// NofBins returns the number of bins
+func (h *H1) NofBins() int { return h.x.N }
+
+// Min returns the low edge of the first bin
+func (h *H1) Min() float64 { return h.x.Min }
+
+// Max returns the high edge of the last bin
+func (h *H1) Max() float64 { return h.x.Max }
+
+// Bin returns the bin of x, -1 for underflow and NofBins() for overflow
+func (h *H1) Bin(x float64) int { return h.x.bin(x) }
+
+// BinLow returns the low edge of bin i
+func (h *H1) BinLow(i int) float64 { return h.x.low(i) }
+
+// BinCenter returns the center of bin i
+func (h *H1) BinCenter(i int) float64 { return h.x.center(i) }
+
+// Content returns the content of bin i, including the
+// underflow (i<0) and overflow (i>=NofBins()) bins
+func (h *H1) Content(i int) float64 {
+	if i < 0 {
+		return h.underflow
+	}
+	if i >= h.x.N {
+		return h.overflow
+	}
+	return h.bins[i]
+}
+
+// Underflow returns the sum of the weights below Min()
+func (h *H1) Underflow() float64 { return h.underflow }
+
+// Overflow returns the sum of the weights above Max()
+func (h *H1) Overflow() float64 { return h.overflow }
+
+// Entries returns the number of fills
+func (h *H1) Entries() int { return h.entries }
+
+// Sum returns the sum of the weights of the bins,
+// excluding under and overflow
+func (h *H1) Sum() float64 {
+	s := 0.0
+	for _, w := range h.bins {
+		s += w
+	}
+	return s
+}
+
+// Mean returns the mean of the bin centers weighted by the bin
+// contents, excluding under and overflow
+func (h *H1) Mean() float64 {
+	m, _ := h.moments()
+	return m
+}
+
+// RMS returns the standard deviation of the bin centers weighted by
+// the bin contents, excluding under and overflow
+func (h *H1) RMS() float64 {
+	_, rms := h.moments()
+	return rms
+}
+
+func (h *H1) moments() (float64, float64) {
+	var sw, sx, sx2 float64
+	for i, w := range h.bins {
+		x := h.x.center(i)
+		sw += w
+		sx += w * x
+		sx2 += w * x * x
+	}
+	if sw == 0 {
+		return 0, 0
+	}
+	m := sx / sw
+	return m, math.Sqrt(math.Max(0, sx2/sw-m*m))
+}
+
+// Merge adds the content of o, which must have the same binning
+func (h *H1) Merge(o *H1) error {
+	if h.x != o.x {
+		return ErrIncompatible
+	}
+	for i, w := range o.bins {
+		h.bins[i] += w
+	}
+	h.underflow += o.underflow
+	h.overflow += o.overflow
+	h.entries += o.entries
+	return nil
+}
+
+// Reset clears the histogram
+func (h *H1) Reset() {
+	for i := range h.bins {
+		h.bins[i] = 0
+	}
+	h.underflow, h.overflow, h.entries = 0, 0, 0
+}
+
+// H2 is a two dimensional histogram
+type H2 struct {
+	Name    string
+	x, y    axis
+	bins    []float64 // x major
+	outside float64
+	entries int
+}
+
+// NewH2 returns an empty histogram of nx bins between xmin and xmax
+// and ny bins between ymin and ymax
+func NewH2(name string, nx int, xmin, xmax float64, ny int, ymin, ymax float64) *H2 {
+	if nx < 1 || ny < 1 || xmax <= xmin || ymax <= ymin {
+		panic("hist: invalid binning")
+	}
+	return &H2{Name: name, x: axis{nx, xmin, xmax}, y: axis{ny, ymin, ymax}, bins: make([]float64, nx*ny)}
+}
+
+// Fill adds one to the bin of (x,y)
+func (h *H2) Fill(x, y float64) {
+	h.FillW(x, y, 1)
+}
+
+// FillW adds w to the bin of (x,y)
+func (h *H2) FillW(x, y, w float64) {
+	h.entries++
+	i, j := h.x.bin(x), h.y.bin(y)
+	if i < 0 || i >= h.x.N || j < 0 || j >= h.y.N {
+		h.outside += w
+		return
+	}
+	h.bins[i*h.y.N+j] += w
+}
+
+// NofBinsX returns the number of bins along x
+func (h *H2) NofBinsX() int { return h.x.N }
+
+// NofBinsY returns the number of bins along y
+func (h *H2) NofBinsY() int { return h.y.N }
+
+// BinLowX returns the low edge of bin i along x
+func (h *H2) BinLowX(i int) float64 { return h.x.low(i) }
+
+// BinLowY returns the low edge of bin j along y
+func (h *H2) BinLowY(j int) float64 { return h.y.low(j) }
+
+// Content returns the content of bin (i,j), or 0 for an out of range bin
+func (h *H2) Content(i, j int) float64 {
+	if i < 0 || i >= h.x.N || j < 0 || j >= h.y.N {
+		return 0
+	}
+	return h.bins[i*h.y.N+j]
+}
+
+// Outside returns the sum of the weights falling outside the bins
+// (the under and overflows of both axis)
+func (h *H2) Outside() float64 { return h.outside }
+
+// Entries returns the number of fills
+func (h *H2) Entries() int { return h.entries }
+
+// MeanX returns the mean along x, excluding the weights outside the bins
+func (h *H2) MeanX() float64 {
+	m, _ := h.moments(true)
+	return m
+}
+
+// MeanY returns the mean along y, excluding the weights outside the bins
+func (h *H2) MeanY() float64 {
+	m, _ := h.moments(false)
+	return m
+}
+
+// RMSX returns the standard deviation along x,
+// excluding the weights outside the bins
+func (h *H2) RMSX() float64 {
+	_, rms := h.moments(true)
+	return rms
+}
+
+// RMSY returns the standard deviation along y,
+// excluding the weights outside the bins
+func (h *H2) RMSY() float64 {
+	_, rms := h.moments(false)
+	return rms
+}
+
+func (h *H2) moments(alongX bool) (float64, float64) {
+	var sw, sx, sx2 float64
+	for i := 0; i < h.x.N; i++ {
+		for j := 0; j < h.y.N; j++ {
+			w := h.bins[i*h.y.N+j]
+			x := h.y.center(j)
+			if alongX {
+				x = h.x.center(i)
+			}
+			sw += w
+			sx += w * x
+			sx2 += w * x * x
+		}
+	}
+	if sw == 0 {
+		return 0, 0
+	}
+	m := sx / sw
+	return m, math.Sqrt(math.Max(0, sx2/sw-m*m))
+}
+
+// Merge adds the content of o, which must have the same binning
+func (h *H2) Merge(o *H2) error {
+	if h.x != o.x || h.y != o.y {
+		return ErrIncompatible
+	}
+	for i, w := range o.bins {
+		h.bins[i] += w
+	}
+	h.outside += o.outside
+	h.entries += o.entries
+	return nil
+}
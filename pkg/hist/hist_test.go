@@ -0,0 +1,92 @@
+package hist
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestH1(t *testing.T) {
+	h := NewH1("adc", 10, 0, 10)
+	for _, x := range []float64{-1, 0, 1.5, 1.2, 9.99, 10, 42} {
+		h.Fill(x)
+	}
+	if h.Underflow() != 1 || h.Overflow() != 2 || h.Entries() != 7 || h.Sum() != 4 {
+		t.Errorf("Expected 1 underflow 2 overflow 7 entries 4 in bins got %v %v %v %v",
+			h.Underflow(), h.Overflow(), h.Entries(), h.Sum())
+	}
+	if h.Content(1) != 2 || h.Bin(9.99) != 9 || h.Bin(10) != 10 || h.Bin(-0.1) != -1 {
+		t.Errorf("Unexpected binning")
+	}
+	// bin centers 0.5, 1.5, 1.5, 9.5
+	if math.Abs(h.Mean()-3.25) > 1e-9 {
+		t.Errorf("Expected mean 3.25 got %v", h.Mean())
+	}
+	expected := math.Sqrt((0.25+2.25+2.25+90.25)/4 - 3.25*3.25)
+	if math.Abs(h.RMS()-expected) > 1e-9 {
+		t.Errorf("Expected rms %v got %v", expected, h.RMS())
+	}
+	o := NewH1("other", 10, 0, 10)
+	o.FillW(1, 3)
+	if err := h.Merge(o); err != nil || h.Content(1) != 5 || h.Entries() != 8 {
+		t.Errorf("Unexpected merge result %v %v", err, h.Content(1))
+	}
+	if err := h.Merge(NewH1("bad", 5, 0, 10)); err != ErrIncompatible {
+		t.Errorf("Expected %v got %v", ErrIncompatible, err)
+	}
+}
+
+func TestH2(t *testing.T) {
+	h := NewH2("map", 4, 0, 4, 2, 0, 2)
+	h.Fill(0.5, 0.5)
+	h.Fill(3.5, 1.5)
+	h.FillW(3.5, 1.5, 2)
+	h.Fill(4, 0)
+	if h.Content(3, 1) != 3 || h.Outside() != 1 || h.Entries() != 4 {
+		t.Errorf("Unexpected contents %v %v %v", h.Content(3, 1), h.Outside(), h.Entries())
+	}
+	if h.MeanX() != 2.75 || h.MeanY() != 1.25 {
+		t.Errorf("Expected means 2.75,1.25 got %v,%v", h.MeanX(), h.MeanY())
+	}
+	o := NewH2("map", 4, 0, 4, 2, 0, 2)
+	o.Fill(0.5, 0.5)
+	if err := h.Merge(o); err != nil || h.Content(0, 0) != 2 {
+		t.Errorf("Unexpected merge result %v %v", err, h.Content(0, 0))
+	}
+	var buf bytes.Buffer
+	h.WriteText(&buf)
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[1], ". . . 3") || !strings.HasSuffix(lines[2], "2 . . .") {
+		t.Errorf("Unexpected text output\n%s", buf.String())
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	h := NewH1("size", 2, 0, 1)
+	h.Fill(0.7)
+	h.Fill(2)
+	var buf bytes.Buffer
+	if err := h.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "low,high,content\n-Inf,0,0\n0,0.5,0\n0.5,1,1\n1,+Inf,1\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q got %q", expected, buf.String())
+	}
+}
+
+func TestWriteTextNegative(t *testing.T) {
+	h := NewH1("background subtracted", 4, 0, 4)
+	h.FillW(1, 2)
+	h.FillW(2, -1)
+	var buf bytes.Buffer
+	if err := h.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[1], "| "+strings.Repeat("#", textWidth)) ||
+		!strings.HasSuffix(lines[2], "| "+strings.Repeat("-", textWidth/2)) {
+		t.Errorf("Unexpected text output\n%s", buf.String())
+	}
+}
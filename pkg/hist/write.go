@@ -0,0 +1,102 @@
+package hist
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteCSV writes the bins of the histogram in CSV, one line per bin
+// with its low and high edges, the underflow and overflow being
+// the first and last lines
+func (h *H1) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"low", "high", "content"})
+	cw.Write([]string{"-Inf", formatFloat(h.x.Min), formatFloat(h.underflow)})
+	for i, c := range h.bins {
+		cw.Write([]string{formatFloat(h.x.low(i)), formatFloat(h.x.low(i + 1)), formatFloat(c)})
+	}
+	cw.Write([]string{formatFloat(h.x.Max), "+Inf", formatFloat(h.overflow)})
+	cw.Flush()
+	return cw.Error()
+}
+
+// textWidth is the length of the longest bar of the text output
+const textWidth = 50
+
+// WriteText writes the statistics of the histogram followed by one
+// line per non empty bin, labelled by its center, with a bar
+// proportional to its content (made of '-' if the content is negative)
+func (h *H1) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s : %d entries mean %g rms %g underflow %g overflow %g\n",
+		h.Name, h.entries, h.Mean(), h.RMS(), h.underflow, h.overflow)
+	max := 0.0
+	for _, c := range h.bins {
+		if math.Abs(c) > max {
+			max = math.Abs(c)
+		}
+	}
+	for i, c := range h.bins {
+		if c == 0 {
+			continue
+		}
+		bar := "#"
+		if c < 0 {
+			bar = "-"
+		}
+		n := int(textWidth * math.Abs(c) / max)
+		fmt.Fprintf(&buf, "%12g %12g | %s\n", h.x.center(i), c, strings.Repeat(bar, n))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteCSV writes the bins of the histogram in CSV,
+// one line per bin with the low edges of its x and y ranges
+func (h *H2) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"x", "y", "content"})
+	for i := 0; i < h.x.N; i++ {
+		for j := 0; j < h.y.N; j++ {
+			cw.Write([]string{formatFloat(h.x.low(i)), formatFloat(h.y.low(j)), formatFloat(h.Content(i, j))})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteText writes the histogram as a text grid, one line per y bin
+// (highest first, labelled by its center) and one column per x bin.
+// Empty bins are shown as '.'.
+func (h *H2) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s : %d entries outside %g\n", h.Name, h.entries, h.outside)
+	width := 2
+	for _, c := range h.bins {
+		if n := len(formatFloat(c)) + 1; n > width {
+			width = n
+		}
+	}
+	for j := h.y.N - 1; j >= 0; j-- {
+		fmt.Fprintf(&buf, "%12g |", h.y.center(j))
+		for i := 0; i < h.x.N; i++ {
+			if c := h.Content(i, j); c != 0 {
+				fmt.Fprintf(&buf, "%*s", width, formatFloat(c))
+			} else {
+				fmt.Fprintf(&buf, "%*s", width, ".")
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,293 @@
+// Package occupancy counts the packets, clusters and samples
+// of each channel over a run, and finds dead and hot channels
+package occupancy
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mrrtf/sampa/pkg/hist"
+	"github.com/mrrtf/sampa/pkg/pedestal"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+// NofChannels is the number of channels of a SAMPA chip
+const NofChannels = 32
+
+// DefaultHotFactor is the default number of times the median number
+// of clusters (of the channels with data) above which a channel is hot
+const DefaultHotFactor = 10.0
+
+// Channel is the occupancy of one channel
+type Channel struct {
+	pedestal.ChannelID
+	Packets  int
+	Clusters int
+	Samples  int
+	Dead     bool // no cluster at all
+	Hot      bool // clusters far above the median
+}
+
+// Chip identifies a SAMPA chip
+type Chip struct {
+	ELink int
+	Hadd  int
+}
+
+// chipCounts holds the counts of the channels of a chip
+type chipCounts struct {
+	packets, clusters, samples *hist.H1
+}
+
+func newChipCounts(chip Chip) *chipCounts {
+	name := fmt.Sprintf("elink %d hadd %d ", chip.ELink, chip.Hadd)
+	return &chipCounts{
+		packets:  hist.NewH1(name+"packets", NofChannels, -0.5, NofChannels-0.5),
+		clusters: hist.NewH1(name+"clusters", NofChannels, -0.5, NofChannels-0.5),
+		samples:  hist.NewH1(name+"samples", NofChannels, -0.5, NofChannels-0.5),
+	}
+}
+
+// Counter accumulates the occupancy of the channels
+type Counter struct {
+	// HotFactor is the number of times the median number of clusters
+	// of the channels with data above which a channel is hot
+	HotFactor float64
+	chips     map[Chip]*chipCounts
+	present   map[Chip]bool
+}
+
+// NewCounter returns an empty counter
+func NewCounter() *Counter {
+	return &Counter{HotFactor: DefaultHotFactor, chips: make(map[Chip]*chipCounts), present: make(map[Chip]bool)}
+}
+
+// Expect declares chips which are expected to send data : their channels
+// are reported (dead) even if the chips never send any packet.
+func (c *Counter) Expect(chips ...Chip) {
+	for _, chip := range chips {
+		if c.chips[chip] == nil {
+			c.chips[chip] = newChipCounts(chip)
+		}
+	}
+}
+
+// Add counts the packet. Any packet, including heartbeats, marks its
+// chip as present : all the channels of the present chips are expected
+// to get data, and are reported dead otherwise.
+func (c *Counter) Add(p *sampa.Packet) {
+	chip := Chip{ELink: p.ELink(), Hadd: int(p.Header().Hadd())}
+	counts := c.chips[chip]
+	if counts == nil {
+		counts = newChipCounts(chip)
+		c.chips[chip] = counts
+	}
+	c.present[chip] = true
+	if uint(p.Header().PKT()) == sampa.HeartBeatPKT {
+		return
+	}
+	chadd := float64(p.Header().CHadd())
+	counts.packets.Fill(chadd)
+	counts.clusters.FillW(chadd, float64(len(p.Clusters())))
+	for _, cl := range p.Clusters() {
+		counts.samples.FillW(chadd, float64(len(cl.Samples())))
+	}
+}
+
+// Chips returns the chips seen so far or expected, ordered by elink
+// and address
+func (c *Counter) Chips() []Chip {
+	var chips []Chip
+	for chip := range c.chips {
+		chips = append(chips, chip)
+	}
+	sort.Sort(byChip(chips))
+	return chips
+}
+
+// MissingChips returns the expected chips which have not sent any
+// packet, ordered by elink and address
+func (c *Counter) MissingChips() []Chip {
+	var chips []Chip
+	for _, chip := range c.Chips() {
+		if !c.present[chip] {
+			chips = append(chips, chip)
+		}
+	}
+	return chips
+}
+
+// Channels returns the occupancy of all the channels of the chips
+// seen so far or expected, ordered by elink, chip and channel, with the dead
+// and hot flags set
+func (c *Counter) Channels() []Channel {
+	var channels []Channel
+	for _, chip := range c.Chips() {
+		counts := c.chips[chip]
+		for i := 0; i < NofChannels; i++ {
+			channels = append(channels, Channel{
+				ChannelID: pedestal.ChannelID{ELink: chip.ELink, Hadd: chip.Hadd, CHadd: i},
+				Packets:   int(counts.packets.Content(i)),
+				Clusters:  int(counts.clusters.Content(i)),
+				Samples:   int(counts.samples.Content(i)),
+			})
+		}
+	}
+	median := Median(channels)
+	for i := range channels {
+		ch := &channels[i]
+		ch.Dead = ch.Clusters == 0
+		ch.Hot = median > 0 && float64(ch.Clusters) > c.HotFactor*median
+	}
+	return channels
+}
+
+// Median returns the median number of clusters of the channels
+// with at least one cluster
+func Median(channels []Channel) float64 {
+	var v []int
+	for _, ch := range channels {
+		if ch.Clusters > 0 {
+			v = append(v, ch.Clusters)
+		}
+	}
+	n := len(v)
+	if n == 0 {
+		return 0
+	}
+	sort.Ints(v)
+	if n%2 == 1 {
+		return float64(v[n/2])
+	}
+	return float64(v[n/2-1]+v[n/2]) / 2
+}
+
+// ParseChips parses a comma separated list of elink:hadd chips, where
+// the elink and the address can be ranges, e.g. "0-39:0-1,40:3"
+func ParseChips(s string) ([]Chip, error) {
+	var chips []Chip
+	for _, x := range strings.Split(s, ",") {
+		x = strings.TrimSpace(x)
+		if x == "" {
+			continue
+		}
+		parts := strings.Split(x, ":")
+		if len(parts) != 2 {
+			return nil, errors.New(fmt.Sprintf("occupancy: invalid chip %q, expecting elink:hadd", x))
+		}
+		e0, e1, err := parseRange(parts[0], sampa.NofELinks-1)
+		if err != nil {
+			return nil, err
+		}
+		h0, h1, err := parseRange(parts[1], 15)
+		if err != nil {
+			return nil, err
+		}
+		for e := e0; e <= e1; e++ {
+			for h := h0; h <= h1; h++ {
+				chips = append(chips, Chip{ELink: e, Hadd: h})
+			}
+		}
+	}
+	return chips, nil
+}
+
+// parseRange parses a number or a range a-b of numbers within [0,max]
+func parseRange(s string, max int) (int, int, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	var v [2]int
+	for i, b := range bounds {
+		n, err := strconv.Atoi(strings.TrimSpace(b))
+		if err != nil || n < 0 || n > max {
+			return 0, 0, errors.New(fmt.Sprintf("occupancy: invalid range %q, expecting numbers within 0-%d", s, max))
+		}
+		v[i] = n
+	}
+	if len(bounds) == 1 {
+		v[1] = v[0]
+	}
+	if v[1] < v[0] {
+		return 0, 0, errors.New(fmt.Sprintf("occupancy: invalid range %q", s))
+	}
+	return v[0], v[1], nil
+}
+
+type byChip []Chip
+
+func (b byChip) Len() int { return len(b) }
+func (b byChip) Less(i, j int) bool {
+	if b[i].ELink != b[j].ELink {
+		return b[i].ELink < b[j].ELink
+	}
+	return b[i].Hadd < b[j].Hadd
+}
+func (b byChip) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+
+// WriteCSV writes the hit map in CSV, with a header line
+func WriteCSV(w io.Writer, channels []Channel) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"elink", "hadd", "chadd", "packets", "clusters", "samples", "dead", "hot"}); err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		err := cw.Write([]string{
+			strconv.Itoa(ch.ELink),
+			strconv.Itoa(ch.Hadd),
+			strconv.Itoa(ch.CHadd),
+			strconv.Itoa(ch.Packets),
+			strconv.Itoa(ch.Clusters),
+			strconv.Itoa(ch.Samples),
+			strconv.FormatBool(ch.Dead),
+			strconv.FormatBool(ch.Hot)})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteGrid writes the number of clusters of each channel as a text
+// grid, one line per chip and one column per channel.
+// Dead channels are shown as '.' and hot channels are followed by '!'.
+func WriteGrid(w io.Writer, channels []Channel) error {
+	width := 2
+	for _, ch := range channels {
+		if n := len(strconv.Itoa(ch.Clusters)) + 2; n > width {
+			width = n
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%5s %4s |", "elink", "hadd")
+	for i := 0; i < NofChannels; i++ {
+		fmt.Fprintf(&buf, "%*d", width, i)
+	}
+	buf.WriteByte('\n')
+	for i, ch := range channels {
+		if i == 0 || ch.ELink != channels[i-1].ELink || ch.Hadd != channels[i-1].Hadd {
+			if i > 0 {
+				buf.WriteByte('\n')
+			}
+			fmt.Fprintf(&buf, "%5d %4d |", ch.ELink, ch.Hadd)
+		}
+		switch {
+		case ch.Dead:
+			fmt.Fprintf(&buf, "%*s", width, ".")
+		case ch.Hot:
+			fmt.Fprintf(&buf, "%*d!", width-1, ch.Clusters)
+		default:
+			fmt.Fprintf(&buf, "%*d", width, ch.Clusters)
+		}
+	}
+	if len(channels) > 0 {
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
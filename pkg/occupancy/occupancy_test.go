@@ -0,0 +1,114 @@
+package occupancy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func TestCounter(t *testing.T) {
+	c := NewCounter()
+	add := func(elink int, hadd, chadd uint, nclusters int) {
+		var clusters []sampa.Cluster
+		for i := 0; i < nclusters; i++ {
+			clusters = append(clusters, sampa.NewCluster(10*i, []int{1, 2}))
+		}
+		p, err := sampa.NewDataPacket(elink, hadd, chadd, 0, clusters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Add(p)
+	}
+	for ch := uint(0); ch < NofChannels; ch++ {
+		if ch != 5 {
+			add(2, 1, ch, 1)
+		}
+	}
+	add(2, 1, 7, 20)
+	hb, _ := sampa.NewHeartBeatPacket(3, 4, 0)
+	c.Add(hb)
+
+	channels := c.Channels()
+	if len(channels) != 2*NofChannels {
+		t.Fatalf("Expected %d channels got %d", 2*NofChannels, len(channels))
+	}
+	ch := channels[7]
+	if ch.Packets != 2 || ch.Clusters != 21 || ch.Samples != 42 || !ch.Hot {
+		t.Errorf("Expected hot channel with 2 packets 21 clusters 42 samples got %+v", ch)
+	}
+	ndead := 0
+	for _, ch := range channels {
+		if ch.Dead {
+			ndead++
+		}
+	}
+	if !channels[5].Dead || ndead != NofChannels+1 {
+		t.Errorf("Expected channel 5 and the heartbeat only chip to be dead, got %d dead", ndead)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGrid(&buf, channels); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines got %d :\n%s", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[1], "    2    1 |") || !strings.Contains(lines[1], "21!") {
+		t.Errorf("Unexpected grid line %q", lines[1])
+	}
+	buf.Reset()
+	if err := WriteCSV(&buf, channels[7:8]); err != nil {
+		t.Fatal(err)
+	}
+	expected := "elink,hadd,chadd,packets,clusters,samples,dead,hot\n2,1,7,2,21,42,false,true\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q got %q", expected, buf.String())
+	}
+}
+
+func TestExpect(t *testing.T) {
+	c := NewCounter()
+	c.Expect(Chip{ELink: 2, Hadd: 1}, Chip{ELink: 0, Hadd: 3})
+	p, err := sampa.NewDataPacket(2, 1, 4, 0, []sampa.Cluster{sampa.NewCluster(0, []int{1})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(p)
+	missing := c.MissingChips()
+	if len(missing) != 1 || missing[0] != (Chip{ELink: 0, Hadd: 3}) {
+		t.Errorf("Expected chip 0:3 to be missing got %v", missing)
+	}
+	channels := c.Channels()
+	if len(channels) != 2*NofChannels {
+		t.Fatalf("Expected %d channels got %d", 2*NofChannels, len(channels))
+	}
+	for _, ch := range channels[:NofChannels] {
+		if !ch.Dead {
+			t.Errorf("Expected the channels of the missing chip to be dead got %+v", ch)
+		}
+	}
+}
+
+func TestParseChips(t *testing.T) {
+	chips, err := ParseChips("0-1:2-3, 39:15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Chip{{0, 2}, {0, 3}, {1, 2}, {1, 3}, {39, 15}}
+	if len(chips) != len(expected) {
+		t.Fatalf("Expected %v got %v", expected, chips)
+	}
+	for i := range chips {
+		if chips[i] != expected[i] {
+			t.Errorf("Expected %v got %v", expected, chips)
+		}
+	}
+	for _, s := range []string{"3", "40:0", "0:16", "3-1:0", "a:0", "0:1:2"} {
+		if _, err := ParseChips(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
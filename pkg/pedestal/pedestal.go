@@ -0,0 +1,209 @@
+// Package pedestal computes the pedestal (mean) and noise (RMS)
+// of each channel from the samples of a pedestal run
+package pedestal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/mrrtf/sampa/pkg/hist"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+// nADC is the number of possible values of a (10 bits) sample
+const nADC = 1024
+
+// DefaultOutlierCut is the default distance to the mean, in RMS
+// units, beyond which a sample is counted as an outlier
+const DefaultOutlierCut = 5.0
+
+// nIterations is the number of times the mean and RMS are computed
+// again without the outliers of the previous computation
+const nIterations = 2
+
+// ChannelID identifies a channel : a channel of a SAMPA chip
+// of the dual sampa read by an elink
+type ChannelID struct {
+	ELink int
+	Hadd  int
+	CHadd int
+}
+
+// Less orders the channels by elink, chip and channel
+func (c ChannelID) Less(o ChannelID) bool {
+	if c.ELink != o.ELink {
+		return c.ELink < o.ELink
+	}
+	if c.Hadd != o.Hadd {
+		return c.Hadd < o.Hadd
+	}
+	return c.CHadd < o.CHadd
+}
+
+// Pedestal is the result of the analysis of one channel
+type Pedestal struct {
+	ChannelID
+	Mean            float64 // mean of the samples which are not outliers
+	RMS             float64 // RMS of the samples which are not outliers
+	N               int     // number of samples, outliers included
+	OutlierFraction float64 // fraction of the samples beyond OutlierCut RMS of the mean
+}
+
+// Accumulator collects the samples of all the channels
+type Accumulator struct {
+	// OutlierCut is the distance to the mean, in RMS units, beyond
+	// which a sample is counted as an outlier
+	OutlierCut float64
+	channels   map[ChannelID]*hist.H1
+}
+
+// NewAccumulator returns an empty accumulator
+func NewAccumulator() *Accumulator {
+	return &Accumulator{OutlierCut: DefaultOutlierCut, channels: make(map[ChannelID]*hist.H1)}
+}
+
+// Add adds the samples of all the clusters of the packet
+// to the channel of the packet
+func (a *Accumulator) Add(p *sampa.Packet) {
+	if len(p.Clusters()) == 0 {
+		return
+	}
+	id := ChannelID{ELink: p.ELink(), Hadd: int(p.Header().Hadd()), CHadd: int(p.Header().CHadd())}
+	h := a.channels[id]
+	if h == nil {
+		h = newADCHistogram(id)
+		a.channels[id] = h
+	}
+	for _, c := range p.Clusters() {
+		for _, s := range c.Samples() {
+			h.Fill(float64(s))
+		}
+	}
+}
+
+// NofChannels returns the number of channels with samples
+func (a *Accumulator) NofChannels() int {
+	return len(a.channels)
+}
+
+// Pedestals returns the pedestals of all the channels,
+// ordered by elink, chip and channel
+func (a *Accumulator) Pedestals() []Pedestal {
+	var peds []Pedestal
+	for id, h := range a.channels {
+		peds = append(peds, compute(id, h, a.OutlierCut))
+	}
+	sort.Sort(byChannel(peds))
+	return peds
+}
+
+// newADCHistogram returns the histogram of the samples of a channel,
+// with one bin per ADC value
+func newADCHistogram(id ChannelID) *hist.H1 {
+	return hist.NewH1(fmt.Sprintf("adc %d-%d-%d", id.ELink, id.Hadd, id.CHadd), nADC, -0.5, nADC-0.5)
+}
+
+// Histogram returns the distribution of the samples of a channel,
+// or nil if the channel has no sample
+func (a *Accumulator) Histogram(id ChannelID) *hist.H1 {
+	return a.channels[id]
+}
+
+// compute computes the pedestal of one channel from its distribution
+// of samples. Values outside the ADC range are ignored.
+// The mean and RMS of all the samples are computed first, then
+// computed again (nIterations times) with only the samples within
+// cut RMS of the previous mean.
+func compute(id ChannelID, h *hist.H1, cut float64) Pedestal {
+	p := Pedestal{ChannelID: id, N: int(h.Sum())}
+	if p.N == 0 {
+		return p
+	}
+	p.Mean = h.Mean()
+	p.RMS = h.RMS()
+	noutliers := 0.0
+	for iter := 0; iter < nIterations && p.RMS > 0; iter++ {
+		var n, sum, sum2 float64
+		for i := 0; i < h.NofBins(); i++ {
+			x := h.BinCenter(i)
+			if math.Abs(x-p.Mean) > cut*p.RMS {
+				continue
+			}
+			w := h.Content(i)
+			n += w
+			sum += w * x
+			sum2 += w * x * x
+		}
+		if n == 0 {
+			break
+		}
+		noutliers = float64(p.N) - n
+		p.Mean = sum / n
+		p.RMS = math.Sqrt(math.Max(sum2/n-p.Mean*p.Mean, 0))
+	}
+	p.OutlierFraction = noutliers / float64(p.N)
+	return p
+}
+
+type byChannel []Pedestal
+
+func (b byChannel) Len() int           { return len(b) }
+func (b byChannel) Less(i, j int) bool { return b[i].ChannelID.Less(b[j].ChannelID) }
+func (b byChannel) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// csvHeader is the first line of the CSV pedestal table
+var csvHeader = []string{"elink", "hadd", "chadd", "mean", "rms", "n", "outliers"}
+
+// WriteCSV writes the pedestal table in CSV, with a header line
+func WriteCSV(w io.Writer, peds []Pedestal) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range peds {
+		err := cw.Write([]string{
+			strconv.Itoa(p.ELink),
+			strconv.Itoa(p.Hadd),
+			strconv.Itoa(p.CHadd),
+			strconv.FormatFloat(p.Mean, 'f', 3, 64),
+			strconv.FormatFloat(p.RMS, 'f', 3, 64),
+			strconv.Itoa(p.N),
+			strconv.FormatFloat(p.OutlierFraction, 'g', 6, 64)})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonPedestal is the JSON representation of a Pedestal
+type jsonPedestal struct {
+	ELink           int     `json:"elink"`
+	Hadd            int     `json:"hadd"`
+	CHadd           int     `json:"chadd"`
+	Mean            float64 `json:"mean"`
+	RMS             float64 `json:"rms"`
+	N               int     `json:"n"`
+	OutlierFraction float64 `json:"outliers"`
+}
+
+// WriteJSON writes the pedestal table as a JSON array
+func WriteJSON(w io.Writer, peds []Pedestal) error {
+	out := make([]jsonPedestal, len(peds))
+	for i, p := range peds {
+		out[i] = jsonPedestal{p.ELink, p.Hadd, p.CHadd, p.Mean, p.RMS, p.N, p.OutlierFraction}
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
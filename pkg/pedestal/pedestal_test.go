@@ -0,0 +1,70 @@
+package pedestal
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func testPacket(t *testing.T, elink int, hadd, chadd uint, samples ...int) *sampa.Packet {
+	p, err := sampa.NewDataPacket(elink, hadd, chadd, 0, []sampa.Cluster{sampa.NewCluster(0, samples)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPedestals(t *testing.T) {
+	a := NewAccumulator()
+	a.OutlierCut = 3
+	samples := []int{100, 102, 98, 100, 101, 99, 100, 100, 102, 98}
+	for i := 0; i < 10; i++ {
+		a.Add(testPacket(t, 3, 1, 7, samples...))
+	}
+	a.Add(testPacket(t, 3, 1, 7, 300))
+	a.Add(testPacket(t, 0, 2, 5, 50, 50))
+	if a.NofChannels() != 2 {
+		t.Fatalf("Expected 2 channels got %d", a.NofChannels())
+	}
+	peds := a.Pedestals()
+	if peds[0].ChannelID != (ChannelID{0, 2, 5}) || peds[1].ChannelID != (ChannelID{3, 1, 7}) {
+		t.Errorf("Unexpected channel order %v", peds)
+	}
+	if peds[0].Mean != 50 || peds[0].RMS != 0 || peds[0].N != 2 || peds[0].OutlierFraction != 0 {
+		t.Errorf("Unexpected pedestal %v", peds[0])
+	}
+	p := peds[1]
+	if p.N != 101 || math.Abs(p.Mean-100) > 1e-9 || math.Abs(p.RMS-math.Sqrt(1.8)) > 1e-9 {
+		t.Errorf("Expected %d samples, mean 100 and rms %f without the outlier got %v", 101, math.Sqrt(1.8), p)
+	}
+	if math.Abs(p.OutlierFraction-1.0/101) > 1e-9 {
+		t.Errorf("Expected outlier fraction %f got %f", 1.0/101, p.OutlierFraction)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	peds := []Pedestal{{ChannelID{1, 2, 3}, 100.5, 1.25, 10, 0.1}}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, peds); err != nil {
+		t.Fatal(err)
+	}
+	expected := "elink,hadd,chadd,mean,rms,n,outliers\n1,2,3,100.500,1.250,10,0.1\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q got %q", expected, buf.String())
+	}
+	buf.Reset()
+	if err := WriteJSON(&buf, peds); err != nil {
+		t.Fatal(err)
+	}
+	var out []jsonPedestal
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Mean != 100.5 || out[0].CHadd != 3 || !strings.Contains(buf.String(), `"outliers"`) {
+		t.Errorf("Unexpected JSON %s", buf.String())
+	}
+}
@@ -0,0 +1,89 @@
+package pedestal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var ErrInvalidTable = errors.New("pedestal: invalid pedestal table")
+
+// Table gives the pedestal of each channel
+type Table map[ChannelID]Pedestal
+
+// NewTable returns the table of the given pedestals
+func NewTable(peds []Pedestal) Table {
+	t := make(Table)
+	for _, p := range peds {
+		t[p.ChannelID] = p
+	}
+	return t
+}
+
+// ReadCSV reads a pedestal table written by WriteCSV
+func ReadCSV(r io.Reader) ([]Pedestal, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) != len(csvHeader) {
+		return nil, ErrInvalidTable
+	}
+	var peds []Pedestal
+	for i, rec := range records[1:] {
+		var p Pedestal
+		var errs [7]error
+		p.ELink, errs[0] = strconv.Atoi(rec[0])
+		p.Hadd, errs[1] = strconv.Atoi(rec[1])
+		p.CHadd, errs[2] = strconv.Atoi(rec[2])
+		p.Mean, errs[3] = strconv.ParseFloat(rec[3], 64)
+		p.RMS, errs[4] = strconv.ParseFloat(rec[4], 64)
+		p.N, errs[5] = strconv.Atoi(rec[5])
+		p.OutlierFraction, errs[6] = strconv.ParseFloat(rec[6], 64)
+		for _, err := range errs {
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("pedestal: line %d : %v", i+2, err))
+			}
+		}
+		peds = append(peds, p)
+	}
+	return peds, nil
+}
+
+// ReadJSON reads a pedestal table written by WriteJSON
+func ReadJSON(r io.Reader) ([]Pedestal, error) {
+	var in []jsonPedestal
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	peds := make([]Pedestal, len(in))
+	for i, p := range in {
+		peds[i] = Pedestal{ChannelID{p.ELink, p.Hadd, p.CHadd}, p.Mean, p.RMS, p.N, p.OutlierFraction}
+	}
+	return peds, nil
+}
+
+// LoadTable reads a pedestal table file, in JSON if its
+// extension is .json, in CSV otherwise
+func LoadTable(filename string) (Table, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var peds []Pedestal
+	if filepath.Ext(filename) == ".json" {
+		peds, err = ReadJSON(f)
+	} else {
+		peds, err = ReadCSV(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewTable(peds), nil
+}
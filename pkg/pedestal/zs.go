@@ -0,0 +1,100 @@
+package pedestal
+
+import (
+	"math"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+// maxTimestamp is the number of possible (10 bits) cluster timestamps
+const maxTimestamp = 1 << 10
+
+// Suppressor subtracts the pedestals from the samples and applies
+// a zero suppression threshold, i.e. does offline the equivalent
+// of the on-chip zero suppression
+type Suppressor struct {
+	table Table
+	// Threshold is the value (after pedestal subtraction) a sample must
+	// exceed to be kept, in ADC counts, or in units of the channel
+	// noise if InSigma is set
+	Threshold float64
+	InSigma   bool
+	// Trim, if set, removes the samples below threshold at both ends
+	// of the clusters. Otherwise clusters are kept whole or dropped,
+	// depending on whether any of their samples is above threshold.
+	Trim     bool
+	nunknown int
+}
+
+// NewSuppressor returns a suppressor using the pedestals of the table,
+// with a zero threshold
+func NewSuppressor(t Table) *Suppressor {
+	return &Suppressor{table: t}
+}
+
+// Subtract returns the cluster with the pedestal subtracted
+// from each sample (rounded to the nearest integer)
+func Subtract(c sampa.Cluster, ped Pedestal) sampa.Cluster {
+	samples := make([]int, len(c.Samples()))
+	for i, s := range c.Samples() {
+		samples[i] = int(math.Floor(float64(s) - ped.Mean + 0.5))
+	}
+	return sampa.NewCluster(c.Timestamp(), samples)
+}
+
+// threshold returns the threshold of the channel, in ADC counts
+func (z *Suppressor) threshold(ped Pedestal) float64 {
+	if z.InSigma {
+		return z.Threshold * ped.RMS
+	}
+	return z.Threshold
+}
+
+// Apply returns the packet with its clusters pedestal subtracted and
+// zero suppressed, or nil if no cluster survives.
+// The packets of channels not in the table are returned unchanged.
+func (z *Suppressor) Apply(p *sampa.Packet) (*sampa.Packet, error) {
+	if len(p.Clusters()) == 0 {
+		return p, nil
+	}
+	id := ChannelID{ELink: p.ELink(), Hadd: int(p.Header().Hadd()), CHadd: int(p.Header().CHadd())}
+	ped, ok := z.table[id]
+	if !ok {
+		z.nunknown++
+		return p, nil
+	}
+	thr := z.threshold(ped)
+	var clusters []sampa.Cluster
+	for _, c := range p.Clusters() {
+		c = Subtract(c, ped)
+		samples := c.Samples()
+		first, last := -1, -1
+		for i, s := range samples {
+			if float64(s) > thr {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		if z.Trim {
+			// the timestamps are 10 bits and wrap around, as the
+			// time counter of the chip does
+			c = sampa.NewCluster((c.Timestamp()+first)%maxTimestamp, samples[first:last+1])
+		}
+		clusters = append(clusters, c)
+	}
+	if len(clusters) == 0 {
+		return nil, nil
+	}
+	return p.WithClusters(clusters)
+}
+
+// NofUnknownChannels returns the number of packets found so far
+// for channels without pedestal
+func (z *Suppressor) NofUnknownChannels() int {
+	return z.nunknown
+}
@@ -0,0 +1,103 @@
+package pedestal
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func TestReadTable(t *testing.T) {
+	peds := []Pedestal{{ChannelID{1, 2, 3}, 100.5, 1.25, 10, 0.1}, {ChannelID{4, 0, 31}, 50, 2, 7, 0}}
+	var buf bytes.Buffer
+	WriteCSV(&buf, peds)
+	got, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, peds) {
+		t.Errorf("CSV : expected %v got %v", peds, got)
+	}
+	buf.Reset()
+	WriteJSON(&buf, peds)
+	got, err = ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, peds) {
+		t.Errorf("JSON : expected %v got %v", peds, got)
+	}
+}
+
+func TestSuppressor(t *testing.T) {
+	table := NewTable([]Pedestal{{ChannelID: ChannelID{3, 1, 7}, Mean: 100.4, RMS: 2}})
+	p, err := sampa.NewDataPacket(3, 1, 7, 0, []sampa.Cluster{
+		sampa.NewCluster(10, []int{101, 104, 120, 103, 100}),
+		sampa.NewCluster(20, []int{101, 102, 99})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := NewSuppressor(table)
+	z.Threshold = 3
+	q, err := z.Apply(p)
+	if err != nil || q == nil || len(q.Clusters()) != 1 {
+		t.Fatalf("Expected 1 cluster above 3 ADC got %v %v", q, err)
+	}
+	if q.Header().NumWords() != 7 || q.Header().Hadd() != 1 || q.Header().CHadd() != 7 {
+		t.Errorf("Expected the header of a 7 words packet got %s", q.Header().StringAnnotated(" "))
+	}
+	if corrected, err := sampa.CorrectHeader(q.Header()); corrected || err != nil {
+		t.Errorf("Expected a valid header got %v %v", corrected, err)
+	}
+	c := q.Clusters()[0]
+	if c.Timestamp() != 10 || !reflect.DeepEqual(c.Samples(), []int{1, 4, 20, 3, 0}) {
+		t.Errorf("Unexpected cluster %v", c.String())
+	}
+
+	z.InSigma = true
+	z.Trim = true
+	q, _ = z.Apply(p)
+	c = q.Clusters()[0]
+	if c.Timestamp() != 12 || !reflect.DeepEqual(c.Samples(), []int{20}) {
+		t.Errorf("Expected cluster trimmed to the samples above 6 ADC got %v", c.String())
+	}
+
+	z.Threshold = 20
+	if q, _ = z.Apply(p); q != nil {
+		t.Errorf("Expected no cluster above 40 ADC got %v", q)
+	}
+
+	other, _ := sampa.NewDataPacket(0, 0, 0, 0, []sampa.Cluster{sampa.NewCluster(1, []int{1})})
+	if q, _ = z.Apply(other); q != other || z.NofUnknownChannels() != 1 {
+		t.Errorf("Expected packet of unknown channel to be unchanged")
+	}
+}
+
+func TestSuppressorTrimWraps(t *testing.T) {
+	table := NewTable([]Pedestal{{ChannelID: ChannelID{3, 1, 7}, Mean: 100, RMS: 2}})
+	p, err := sampa.NewDataPacket(3, 1, 7, 0, []sampa.Cluster{
+		sampa.NewCluster(1020, []int{100, 100, 100, 100, 100, 150, 130, 100})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := NewSuppressor(table)
+	z.Threshold = 10
+	z.Trim = true
+	q, err := z.Apply(p)
+	if err != nil || q == nil {
+		t.Fatalf("Expected a trimmed packet got %v %v", q, err)
+	}
+	if c := q.Clusters()[0]; c.Timestamp() != 1 || !reflect.DeepEqual(c.Samples(), []int{50, 30}) {
+		t.Errorf("Expected the cluster trimmed at timestamp 1025 modulo 1024 got %v", c.String())
+	}
+	var buf bytes.Buffer
+	w, err := sampa.NewDigitWriter(&buf, sampa.DigitHeader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WritePacket(q); err != nil {
+		t.Errorf("Expected the trimmed packet to be written as digits got %v", err)
+	}
+}
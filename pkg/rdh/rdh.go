@@ -0,0 +1,140 @@
+// Package rdh reads the data written by the CRU (Common Readout Unit),
+// i.e. pages starting with an O2 Raw Data Header (RDH)
+//
+// # See Also
+//
+// O2 RAWDataHeader definitions
+// https://github.com/AliceO2Group/AliceO2/blob/dev/DataFormats/Headers/include/Headers/RAWDataHeader.h
+package rdh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// HeaderSize is the size in bytes of all the supported RDH versions
+	HeaderSize = 64
+	// MinVersion is the oldest supported RDH version
+	MinVersion = 4
+)
+
+var (
+	ErrShortHeader      = errors.New("rdh: not enough bytes for a header")
+	ErrInvalidVersion   = errors.New("rdh: unsupported header version")
+	ErrInvalidSize      = errors.New("rdh: inconsistent header or page size")
+	ErrPacketCounter    = errors.New("rdh: packet counter jump")
+	ErrPageCounter      = errors.New("rdh: page counter jump")
+	ErrMissingStopBit   = errors.New("rdh: new heartbeat frame without stop bit")
+	ErrUnalignedPayload = errors.New("rdh: payload is not a whole number of GBT words")
+)
+
+// RDH is the Raw Data Header of a CRU page, for versions 4 and later.
+//
+// In version 4 the trigger and heartbeat orbits and bunch crossings
+// are distinct. From version 5 on there is only one orbit and bunch
+// crossing, which is then stored in both the trigger and heartbeat fields.
+type RDH struct {
+	Version        uint8
+	HeaderSize     uint8
+	BlockLength    uint16 // version 4 only
+	FeeID          uint16
+	Priority       uint8
+	SourceID       uint8 // version 5 and later
+	OffsetToNext   uint16
+	MemorySize     uint16
+	LinkID         uint8
+	PacketCounter  uint8
+	CruID          uint16
+	EndPointID     uint8
+	TriggerOrbit   uint32
+	HeartbeatOrbit uint32
+	TriggerBC      uint16
+	HeartbeatBC    uint16
+	TriggerType    uint32
+	DetectorField  uint32
+	Par            uint16
+	StopBit        uint8
+	PageCounter    uint16
+}
+
+// IsRDH returns true if b looks like it starts with a
+// supported Raw Data Header
+func IsRDH(b []byte) bool {
+	return len(b) >= HeaderSize && b[0] >= MinVersion && b[0] < 16 && b[1] == HeaderSize
+}
+
+// Decode decodes the Raw Data Header at the start of b
+func Decode(b []byte) (RDH, error) {
+	var h RDH
+	if len(b) < HeaderSize {
+		return h, ErrShortHeader
+	}
+	w0 := binary.LittleEndian.Uint64(b[0:8])
+	w1 := binary.LittleEndian.Uint64(b[8:16])
+	w2 := binary.LittleEndian.Uint64(b[16:24])
+	w4 := binary.LittleEndian.Uint64(b[32:40])
+	w6 := binary.LittleEndian.Uint64(b[48:56])
+
+	h.Version = uint8(w0)
+	h.HeaderSize = uint8(w0 >> 8)
+	if h.Version < MinVersion {
+		return h, ErrInvalidVersion
+	}
+	if h.HeaderSize != HeaderSize {
+		return h, ErrInvalidSize
+	}
+
+	h.OffsetToNext = uint16(w1)
+	h.MemorySize = uint16(w1 >> 16)
+	h.LinkID = uint8(w1 >> 32)
+	h.PacketCounter = uint8(w1 >> 40)
+	h.CruID = uint16(w1>>48) & 0xFFF
+	h.EndPointID = uint8(w1>>60) & 0xF
+
+	if h.Version == 4 {
+		h.BlockLength = uint16(w0 >> 16)
+		h.FeeID = uint16(w0 >> 32)
+		h.Priority = uint8(w0 >> 48)
+		h.TriggerOrbit = uint32(w2)
+		h.HeartbeatOrbit = uint32(w2 >> 32)
+		h.TriggerBC = uint16(w4) & 0xFFF
+		h.HeartbeatBC = uint16(w4>>16) & 0xFFF
+		h.TriggerType = uint32(w4 >> 32)
+		h.DetectorField = uint32(w6) & 0xFFFF
+		h.Par = uint16(w6 >> 16)
+		h.StopBit = uint8(w6 >> 32)
+		h.PageCounter = uint16(w6 >> 40)
+	} else {
+		h.FeeID = uint16(w0 >> 16)
+		h.Priority = uint8(w0 >> 32)
+		h.SourceID = uint8(w0 >> 40)
+		h.TriggerBC = uint16(w2) & 0xFFF
+		h.HeartbeatBC = h.TriggerBC
+		h.TriggerOrbit = uint32(w2 >> 32)
+		h.HeartbeatOrbit = h.TriggerOrbit
+		h.TriggerType = uint32(w4)
+		h.PageCounter = uint16(w4 >> 32)
+		h.StopBit = uint8(w4 >> 48)
+		h.DetectorField = uint32(w6)
+		h.Par = uint16(w6 >> 32)
+	}
+
+	if h.MemorySize < HeaderSize || (h.OffsetToNext != 0 && h.OffsetToNext < h.MemorySize) {
+		return h, ErrInvalidSize
+	}
+	return h, nil
+}
+
+// Link returns a number identifying the GBT link of the page
+// within the whole readout : CRU, end point and link ids
+func (h RDH) Link() int {
+	return int(h.CruID)<<12 | int(h.EndPointID)<<8 | int(h.LinkID)
+}
+
+func (h RDH) String() string {
+	return fmt.Sprintf("RDHv%d fee %d cru %d ep %d link %d orbit %d bc %d trigger %08X packet %d page %d stop %d size %d/%d",
+		h.Version, h.FeeID, h.CruID, h.EndPointID, h.LinkID, h.HeartbeatOrbit, h.HeartbeatBC,
+		h.TriggerType, h.PacketCounter, h.PageCounter, h.StopBit, h.MemorySize, h.OffsetToNext)
+}
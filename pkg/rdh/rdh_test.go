@@ -0,0 +1,124 @@
+package rdh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// page returns a CRU page with a header of the given version
+// followed by nwords GBT words, each stored in 16 bytes
+func page(version uint8, link uint8, packet uint8, orbit uint32, pageCounter uint16, stop uint8, nwords int) []byte {
+	size := HeaderSize + nwords*nBytesPerGBT
+	b := make([]byte, size)
+	b[0] = version
+	b[1] = HeaderSize
+	binary.LittleEndian.PutUint16(b[8:], uint16(size))
+	binary.LittleEndian.PutUint16(b[10:], uint16(size))
+	b[12] = link
+	b[13] = packet
+	binary.LittleEndian.PutUint16(b[14:], 3|2<<12) // cru 3, end point 2
+	if version == 4 {
+		binary.LittleEndian.PutUint32(b[16:], orbit)
+		binary.LittleEndian.PutUint32(b[20:], orbit)
+		b[52] = stop
+		binary.LittleEndian.PutUint16(b[53:], pageCounter)
+	} else {
+		binary.LittleEndian.PutUint32(b[20:], orbit)
+		binary.LittleEndian.PutUint16(b[36:], pageCounter)
+		b[38] = stop
+	}
+	for i := 0; i < nwords; i++ {
+		for j := 0; j < 10; j++ {
+			b[HeaderSize+i*nBytesPerGBT+j] = byte(i + 1)
+		}
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	for _, v := range []uint8{4, 6} {
+		h, err := Decode(page(v, 5, 7, 1234, 2, 1, 3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Version != v || h.LinkID != 5 || h.PacketCounter != 7 ||
+			h.HeartbeatOrbit != 1234 || h.PageCounter != 2 || h.StopBit != 1 ||
+			h.CruID != 3 || h.EndPointID != 2 || h.MemorySize != HeaderSize+48 {
+			t.Errorf("v%d : unexpected header %v", v, h)
+		}
+		if h.Link() != 3<<12|2<<8|5 {
+			t.Errorf("v%d : expected link %d got %d", v, 3<<12|2<<8|5, h.Link())
+		}
+	}
+	b := page(3, 0, 0, 0, 0, 0, 0)
+	if IsRDH(b) {
+		t.Errorf("Expected version 3 not to be recognized")
+	}
+	if _, err := Decode(b); err != ErrInvalidVersion {
+		t.Errorf("Expected %v got %v", ErrInvalidVersion, err)
+	}
+	if _, err := Decode(b[:10]); err != ErrShortHeader {
+		t.Errorf("Expected %v got %v", ErrShortHeader, err)
+	}
+}
+
+func TestReader(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(page(6, 0, 0, 10, 0, 0, 2))
+	buf.Write(page(6, 1, 0, 10, 0, 1, 1))
+	buf.Write(page(6, 0, 1, 10, 1, 1, 0))
+	buf.Write(page(6, 0, 2, 11, 0, 0, 3))
+	r := NewReader(&buf)
+	p := make([]byte, 10)
+	expected := []struct{ link, word int }{{0, 1}, {0, 2}, {1, 1}, {0, 1}, {0, 2}, {0, 3}}
+	for i, e := range expected {
+		_, err := r.Read(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Header().LinkID != uint8(e.link) || p[0] != byte(e.word) || p[9] != byte(e.word) {
+			t.Errorf("word %d : expected link %d word %d got link %d word %v", i, e.link, e.word, r.Header().LinkID, p)
+		}
+	}
+	if _, err := r.Read(p); err != io.EOF {
+		t.Errorf("Expected EOF got %v", err)
+	}
+	if r.NofPages() != 4 || r.NofGBTwords() != 6 {
+		t.Errorf("Expected 4 pages and 6 words got %d and %d", r.NofPages(), r.NofGBTwords())
+	}
+	for _, err := range []error{ErrPacketCounter, ErrPageCounter, ErrMissingStopBit, ErrUnalignedPayload} {
+		if r.NofErrors(err) != 0 {
+			t.Errorf("Expected no %v got %d", err, r.NofErrors(err))
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(page(4, 0, 0, 10, 0, 0, 1))
+	buf.Write(page(4, 0, 2, 10, 1, 0, 1)) // packet counter jump
+	buf.Write(page(4, 0, 3, 11, 0, 0, 1)) // no stop bit before orbit 11
+	buf.Write(page(4, 0, 4, 11, 2, 1, 1)) // page counter jump
+	buf.Write(page(4, 0, 5, 12, 1, 0, 1)) // page counter not reset
+	r := NewReader(&buf)
+	p := make([]byte, 10)
+	for {
+		if _, err := r.Read(p); err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	if r.NofErrors(ErrPacketCounter) != 1 {
+		t.Errorf("Expected 1 packet counter error got %d", r.NofErrors(ErrPacketCounter))
+	}
+	if r.NofErrors(ErrMissingStopBit) != 1 {
+		t.Errorf("Expected 1 missing stop bit got %d", r.NofErrors(ErrMissingStopBit))
+	}
+	if r.NofErrors(ErrPageCounter) != 2 {
+		t.Errorf("Expected 2 page counter errors got %d", r.NofErrors(ErrPageCounter))
+	}
+}
@@ -0,0 +1,179 @@
+package rdh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/gbt"
+)
+
+// nBytesPerGBT is the size of a GBT word in the CRU pages :
+// 80 bits of GBT word and 48 bits of padding
+const nBytesPerGBT = 16
+
+// Reader reads the GBT words from a stream of CRU pages.
+//
+// The page and packet counters and the stop bits of each link are
+// checked while reading. The problems found are counted, see NofErrors,
+// but do not stop the reading.
+type Reader struct {
+	r       *bufio.Reader
+	closer  io.Closer
+	header  RDH
+	page    []byte // current page, header included
+	payload []byte // GBT words of the current page
+	pos     int
+	npages  int
+	ngbt    int
+	last    map[int]RDH // header of the previous page of each link
+	nerrors map[error]int
+}
+
+// NewReader returns a Reader of the CRU pages stored in r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), page: make([]byte, 8192),
+		last: make(map[int]RDH), nerrors: make(map[error]int)}
+}
+
+// Open returns a Reader of the CRU pages stored in the given file
+func Open(filename string) (*Reader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := NewReader(file)
+	r.closer = file
+	return r, nil
+}
+
+// Close closes the underlying file, if the reader was created by Open
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// NextPage reads the next page and checks its header against
+// the one of the previous page of the same link
+func (r *Reader) NextPage() error {
+	_, err := io.ReadFull(r.r, r.page[:HeaderSize])
+	if err != nil {
+		return err
+	}
+	h, err := Decode(r.page[:HeaderSize])
+	if err != nil {
+		return err
+	}
+	size := int(h.OffsetToNext)
+	if size == 0 {
+		size = int(h.MemorySize)
+	}
+	if size > len(r.page) {
+		r.page = append(r.page, make([]byte, size-len(r.page))...)
+	}
+	_, err = io.ReadFull(r.r, r.page[HeaderSize:size])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	r.header = h
+	r.npages++
+	r.check(h)
+	n := int(h.MemorySize) - HeaderSize
+	if n%nBytesPerGBT != 0 {
+		r.nerrors[ErrUnalignedPayload]++
+		n -= n % nBytesPerGBT
+	}
+	r.payload = r.page[HeaderSize : HeaderSize+n]
+	r.pos = 0
+	return nil
+}
+
+// check counts the inconsistencies between the header of a page
+// and the header of the previous page of the same link
+func (r *Reader) check(h RDH) {
+	link := h.Link()
+	prev, ok := r.last[link]
+	r.last[link] = h
+	if !ok {
+		return
+	}
+	if h.PacketCounter != prev.PacketCounter+1 {
+		r.nerrors[ErrPacketCounter]++
+	}
+	if prev.StopBit != 0 {
+		// previous heartbeat frame is closed, a new one must start
+		if h.PageCounter != 0 {
+			r.nerrors[ErrPageCounter]++
+		}
+		return
+	}
+	if h.HeartbeatOrbit != prev.HeartbeatOrbit {
+		r.nerrors[ErrMissingStopBit]++
+		return
+	}
+	if h.PageCounter != prev.PageCounter+1 {
+		r.nerrors[ErrPageCounter]++
+	}
+}
+
+// Read fills p with the next GBT word, reading new pages as needed
+func (r *Reader) Read(p []byte) (int, error) {
+	for r.pos >= len(r.payload) {
+		err := r.NextPage()
+		if err != nil {
+			return 0, err
+		}
+	}
+	copy(p, r.payload[r.pos:r.pos+gbt.NofBytes])
+	r.pos += nBytesPerGBT
+	r.ngbt++
+	return gbt.NofBytes, nil
+}
+
+// ReadUint64 returns the next 64 bits word of the payload, reading
+// new pages as needed. It is meant for the pages written by the CRU
+// user logic, which carry 64 bits words instead of GBT words, and
+// should not be mixed with Read.
+func (r *Reader) ReadUint64() (uint64, error) {
+	for r.pos >= len(r.payload) {
+		err := r.NextPage()
+		if err != nil {
+			return 0, err
+		}
+	}
+	w := binary.LittleEndian.Uint64(r.payload[r.pos : r.pos+8])
+	r.pos += 8
+	return w, nil
+}
+
+// Header returns the header of the current page
+func (r *Reader) Header() RDH {
+	return r.header
+}
+
+// Link returns the link of the current page, i.e. of the last GBT word read
+func (r *Reader) Link() int {
+	return r.header.Link()
+}
+
+// NofPages returns the number of pages read so far
+func (r *Reader) NofPages() int {
+	return r.npages
+}
+
+// NofGBTwords returns the number of GBT words read so far
+func (r *Reader) NofGBTwords() int {
+	return r.ngbt
+}
+
+// NofErrors returns the number of times the given problem (ErrPacketCounter,
+// ErrPageCounter, ErrMissingStopBit or ErrUnalignedPayload) was found
+func (r *Reader) NofErrors(err error) int {
+	return r.nerrors[err]
+}
@@ -14,6 +14,86 @@ type Cluster struct {
 	samples []int // samples
 }
 
+// Timestamp returns the time of the first sample of the cluster
+func (c *Cluster) Timestamp() int {
+	return c.ts
+}
+
+// Samples returns the ADC values of the cluster
+func (c *Cluster) Samples() []int {
+	return c.samples
+}
+
+// ClusterInfo summarizes the physics content of a cluster
+type ClusterInfo struct {
+	Timestamp  int     `json:"ts"`
+	NofSamples int     `json:"nsamples"`
+	Baseline   float64 `json:"baseline"` // subtracted from the samples to get the charge
+	Charge     float64 `json:"charge"`
+	Peak       int     `json:"peak"`
+	PeakIndex  int     `json:"peakIndex"`
+	Time       float64 `json:"time"`
+}
+
+// Charge returns the sum of the samples minus the baseline (the
+// pedestal of the channel, or 0 if the samples are already pedestal
+// subtracted), i.e. the integrated charge
+func (c *Cluster) Charge(baseline float64) float64 {
+	q := 0.0
+	for _, s := range c.samples {
+		q += float64(s) - baseline
+	}
+	return q
+}
+
+// Peak returns the maximum amplitude of the cluster and the
+// index of the (first) sample where it is reached,
+// or (0,-1) for an empty cluster
+func (c *Cluster) Peak() (int, int) {
+	peak, index := 0, -1
+	for i, s := range c.samples {
+		if index < 0 || s > peak {
+			peak, index = s, i
+		}
+	}
+	return peak, index
+}
+
+// PeakTime returns the time of the peak, in sample units, i.e. the
+// cluster timestamp plus the peak position. The latter is refined with
+// a parabola through the peak sample and its two neighbours, if any.
+func (c *Cluster) PeakTime() float64 {
+	peak, i := c.Peak()
+	if i < 0 {
+		return float64(c.ts)
+	}
+	t := float64(c.ts + i)
+	if i == 0 || i == len(c.samples)-1 {
+		return t
+	}
+	left, right := float64(c.samples[i-1]), float64(c.samples[i+1])
+	d := left - 2*float64(peak) + right
+	if d == 0 {
+		return t
+	}
+	return t + 0.5*(left-right)/d
+}
+
+// Info returns the charge above baseline (see Charge), the peak and
+// the time of the cluster
+func (c *Cluster) Info(baseline float64) ClusterInfo {
+	peak, index := c.Peak()
+	return ClusterInfo{
+		Timestamp:  c.ts,
+		NofSamples: len(c.samples),
+		Baseline:   baseline,
+		Charge:     c.Charge(baseline),
+		Peak:       peak,
+		PeakIndex:  index,
+		Time:       c.PeakTime(),
+	}
+}
+
 func (c *Cluster) String() string {
 	v := fmt.Sprintf("(%d) [%d]", c.ts, len(c.samples))
 	for _, s := range c.samples {
@@ -0,0 +1,45 @@
+package sampa
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestClusterInfo(t *testing.T) {
+	c := NewCluster(100, []int{2, 10, 30, 20, 4})
+	info := c.Info(0)
+	if info.Charge != 66 {
+		t.Errorf("Expected charge 66 got %v", info.Charge)
+	}
+	if q := c.Info(1.5).Charge; q != 66-5*1.5 {
+		t.Errorf("Expected charge %v above a 1.5 baseline got %v", 66-5*1.5, q)
+	}
+	if info.Peak != 30 || info.PeakIndex != 2 {
+		t.Errorf("Expected peak 30 at 2 got %d at %d", info.Peak, info.PeakIndex)
+	}
+	// parabola through (1,10),(2,30),(3,20) peaks at 2+0.5*(10-20)/(10-60+20)
+	expected := 102 + 1.0/6
+	if math.Abs(info.Time-expected) > 1e-9 {
+		t.Errorf("Expected time %v got %v", expected, info.Time)
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back ClusterInfo
+	if err := json.Unmarshal(b, &back); err != nil || back != info {
+		t.Errorf("Expected %v got %v (%v)", info, back, err)
+	}
+}
+
+func TestClusterPeakAtEdge(t *testing.T) {
+	c := NewCluster(7, []int{5, 3, 1})
+	if c.PeakTime() != 7 {
+		t.Errorf("Expected peak time 7 got %v", c.PeakTime())
+	}
+	empty := NewCluster(3, nil)
+	if _, i := empty.Peak(); i != -1 || empty.Charge(10) != 0 {
+		t.Errorf("Expected no peak and no charge for an empty cluster")
+	}
+}
@@ -0,0 +1,299 @@
+package sampa
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The digit format stores decoded clusters compactly, so that the
+// analyses do not have to decode the raw data again.
+//
+// A digit file starts with the 8 bytes magic "SAMPADIG" followed by
+// the uvarint format version and the header : the uvarint run number,
+// the decoder version and the options (a uvarint count followed by
+// key, value pairs), all strings being written as their uvarint
+// length followed by their bytes.
+//
+// Then comes one record per digit, until the end of the file :
+//
+//	uvarint channel (elink<<9 | hadd<<5 | chadd)
+//	byte    flags
+//	varint  bunch crossing difference to the previous digit
+//	uvarint timestamp
+//	uvarint number of samples
+//	varint  first sample, then difference of each sample to the previous one
+//
+// The bunch crossing is the (20 bits, wrapping around) bunch crossing
+// counter of the header of the packet, in 40 MHz clock ticks, and the
+// timestamp is the (10 bits) timestamp of the cluster, in sampling
+// periods. They are kept apart as the sampling period depends on the
+// configuration of the chips.
+
+var (
+	ErrNotDigitFile      = errors.New("sampa: not a digit file")
+	ErrDigitVersion      = errors.New("sampa: unsupported digit format version")
+	ErrInvalidDigit      = errors.New("sampa: invalid digit")
+	ErrDigitChannelRange = errors.New("sampa: digit channel out of range")
+	ErrDigitTimeRange    = errors.New("sampa: digit bunch crossing or timestamp out of range")
+)
+
+// DigitFormatVersion is the version of the digit format written
+const DigitFormatVersion = 2
+
+var digitMagic = []byte("SAMPADIG")
+
+// maxDigitSamples bounds the number of samples of a digit when reading,
+// to detect garbage (a cluster of a packet can hold at most 1022 samples)
+const maxDigitSamples = 1024
+
+// Flags of a digit, mostly from the type of its packet
+const (
+	FlagTruncated       uint8 = 1 << iota // data truncated
+	FlagTriggerTooEarly                   // trigger too early
+	FlagNumWords                          // number of words anomaly
+)
+
+// HeaderFlags returns the anomaly flags encoded in the packet type
+func HeaderFlags(sdh *SampaDataHeader) uint8 {
+	switch uint(sdh.PKT()) {
+	case DataTruncatedPKT:
+		return FlagTruncated
+	case DataTruncatedTriggerTooEarlyPKT:
+		return FlagTruncated | FlagTriggerTooEarly
+	case DataNumWordsPKT:
+		return FlagNumWords
+	case DataTriggerTooEarlyPKT:
+		return FlagTriggerTooEarly
+	case DataTriggerTooEarlyNumWordsPKT:
+		return FlagTriggerTooEarly | FlagNumWords
+	}
+	return 0
+}
+
+// Digit is a decoded cluster with the address of its channel
+type Digit struct {
+	ELink     int
+	Hadd      int
+	CHadd     int
+	BX        uint32 // bunch crossing counter of the packet
+	Timestamp int    // timestamp of the first sample, in sampling periods
+	Samples   []int
+	Flags     uint8
+}
+
+// DigitHeader describes how a digit file was produced
+type DigitHeader struct {
+	Run            uint64
+	DecoderVersion string
+	Options        map[string]string
+}
+
+// DigitWriter writes digits in the digit format
+type DigitWriter struct {
+	w       *bufio.Writer
+	buf     []byte
+	bx      int64
+	ndigits int
+}
+
+// NewDigitWriter writes the header of a digit file to w and
+// returns the writer of its digits
+func NewDigitWriter(w io.Writer, h DigitHeader) (*DigitWriter, error) {
+	dw := &DigitWriter{w: bufio.NewWriter(w), buf: make([]byte, binary.MaxVarintLen64)}
+	dw.w.Write(digitMagic)
+	dw.putUvarint(DigitFormatVersion)
+	dw.putUvarint(h.Run)
+	dw.putString(h.DecoderVersion)
+	var keys []string
+	for k := range h.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	dw.putUvarint(uint64(len(keys)))
+	for _, k := range keys {
+		dw.putString(k)
+		dw.putString(h.Options[k])
+	}
+	if err := dw.w.Flush(); err != nil {
+		return nil, err
+	}
+	return dw, nil
+}
+
+func (dw *DigitWriter) putUvarint(v uint64) {
+	n := binary.PutUvarint(dw.buf, v)
+	dw.w.Write(dw.buf[:n])
+}
+
+func (dw *DigitWriter) putVarint(v int64) {
+	n := binary.PutVarint(dw.buf, v)
+	dw.w.Write(dw.buf[:n])
+}
+
+func (dw *DigitWriter) putString(s string) {
+	dw.putUvarint(uint64(len(s)))
+	dw.w.WriteString(s)
+}
+
+// Write writes one digit
+func (dw *DigitWriter) Write(d Digit) error {
+	if d.ELink < 0 || d.ELink >= 1<<23 || d.Hadd < 0 || d.Hadd >= 16 || d.CHadd < 0 || d.CHadd >= 32 {
+		return ErrDigitChannelRange
+	}
+	if d.BX >= 1<<20 || d.Timestamp < 0 || d.Timestamp >= 1<<10 {
+		return ErrDigitTimeRange
+	}
+	dw.putUvarint(uint64(d.ELink<<9 | d.Hadd<<5 | d.CHadd))
+	dw.w.WriteByte(d.Flags)
+	dw.putVarint(int64(d.BX) - dw.bx)
+	dw.bx = int64(d.BX)
+	dw.putUvarint(uint64(d.Timestamp))
+	dw.putUvarint(uint64(len(d.Samples)))
+	prev := 0
+	for _, s := range d.Samples {
+		dw.putVarint(int64(s - prev))
+		prev = s
+	}
+	dw.ndigits++
+	return nil
+}
+
+// WritePacket writes one digit per cluster of the packet
+func (dw *DigitWriter) WritePacket(p *Packet) error {
+	flags := HeaderFlags(p.Header())
+	for _, c := range p.Clusters() {
+		err := dw.Write(Digit{
+			ELink:     p.ELink(),
+			Hadd:      int(p.Header().Hadd()),
+			CHadd:     int(p.Header().CHadd()),
+			BX:        p.Header().BXcount(),
+			Timestamp: c.Timestamp(),
+			Samples:   c.Samples(),
+			Flags:     flags,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NofDigits returns the number of digits written so far
+func (dw *DigitWriter) NofDigits() int {
+	return dw.ndigits
+}
+
+// Flush writes the buffered digits to the underlying writer
+func (dw *DigitWriter) Flush() error {
+	return dw.w.Flush()
+}
+
+// DigitReader reads digits written by a DigitWriter
+type DigitReader struct {
+	r      *bufio.Reader
+	header DigitHeader
+	bx     int64
+}
+
+// NewDigitReader reads the header of a digit file from r and
+// returns the reader of its digits
+func NewDigitReader(r io.Reader) (*DigitReader, error) {
+	dr := &DigitReader{r: bufio.NewReader(r)}
+	magic := make([]byte, len(digitMagic))
+	if _, err := io.ReadFull(dr.r, magic); err != nil || string(magic) != string(digitMagic) {
+		return nil, ErrNotDigitFile
+	}
+	version, err := binary.ReadUvarint(dr.r)
+	if err != nil {
+		return nil, ErrNotDigitFile
+	}
+	if version != DigitFormatVersion {
+		return nil, errors.New(fmt.Sprintf("%v : %d", ErrDigitVersion, version))
+	}
+	if dr.header.Run, err = binary.ReadUvarint(dr.r); err != nil {
+		return nil, ErrNotDigitFile
+	}
+	if dr.header.DecoderVersion, err = dr.readString(); err != nil {
+		return nil, err
+	}
+	n, err := binary.ReadUvarint(dr.r)
+	if err != nil {
+		return nil, ErrNotDigitFile
+	}
+	dr.header.Options = make(map[string]string)
+	for i := uint64(0); i < n; i++ {
+		k, err := dr.readString()
+		if err != nil {
+			return nil, err
+		}
+		if dr.header.Options[k], err = dr.readString(); err != nil {
+			return nil, err
+		}
+	}
+	return dr, nil
+}
+
+func (dr *DigitReader) readString() (string, error) {
+	n, err := binary.ReadUvarint(dr.r)
+	if err != nil || n > 1<<16 {
+		return "", ErrNotDigitFile
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(dr.r, b); err != nil {
+		return "", ErrNotDigitFile
+	}
+	return string(b), nil
+}
+
+// Header returns the header of the digit file
+func (dr *DigitReader) Header() DigitHeader {
+	return dr.header
+}
+
+// Read returns the next digit, io.EOF at the end of the file, or
+// ErrInvalidDigit if the file ends in the middle of a digit or
+// holds garbage
+func (dr *DigitReader) Read() (Digit, error) {
+	var d Digit
+	channel, err := binary.ReadUvarint(dr.r)
+	if err == io.EOF {
+		return d, io.EOF
+	}
+	if err != nil || channel >= 1<<32 {
+		return d, ErrInvalidDigit
+	}
+	d.ELink, d.Hadd, d.CHadd = int(channel>>9), int(channel>>5)&0xF, int(channel)&0x1F
+	if d.Flags, err = dr.r.ReadByte(); err != nil {
+		return d, ErrInvalidDigit
+	}
+	dbx, err := binary.ReadVarint(dr.r)
+	if err != nil || dr.bx+dbx < 0 || dr.bx+dbx >= 1<<20 {
+		return d, ErrInvalidDigit
+	}
+	dr.bx += dbx
+	d.BX = uint32(dr.bx)
+	ts, err := binary.ReadUvarint(dr.r)
+	if err != nil || ts >= 1<<10 {
+		return d, ErrInvalidDigit
+	}
+	d.Timestamp = int(ts)
+	n, err := binary.ReadUvarint(dr.r)
+	if err != nil || n > maxDigitSamples {
+		return d, ErrInvalidDigit
+	}
+	d.Samples = make([]int, n)
+	prev := 0
+	for i := range d.Samples {
+		ds, err := binary.ReadVarint(dr.r)
+		if err != nil {
+			return d, ErrInvalidDigit
+		}
+		prev += int(ds)
+		d.Samples[i] = prev
+	}
+	return d, nil
+}
@@ -0,0 +1,89 @@
+package sampa
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDigitRoundTrip(t *testing.T) {
+	h := DigitHeader{Run: 1234, DecoderVersion: "test", Options: map[string]string{"elink-mask": "0", "zs-threshold": "3"}}
+	digits := []Digit{
+		{ELink: 39, Hadd: 15, CHadd: 31, BX: 1<<20 - 1, Timestamp: 1023, Samples: []int{0, 1023, 512}, Flags: FlagTruncated},
+		{ELink: 0, Hadd: 0, CHadd: 0, BX: 12, Timestamp: 0, Samples: []int{-3, 5}},
+		{ELink: 7, Hadd: 2, CHadd: 9, BX: 12, Timestamp: 7, Samples: []int{}},
+	}
+	var buf bytes.Buffer
+	w, err := NewDigitWriter(&buf, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range digits {
+		if err := w.Write(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+	if err := w.Write(Digit{Hadd: 16}); err != ErrDigitChannelRange {
+		t.Errorf("Expected %v got %v", ErrDigitChannelRange, err)
+	}
+	for _, d := range []Digit{{BX: 1 << 20}, {Timestamp: 1 << 10}, {Timestamp: -1}} {
+		if err := w.Write(d); err != ErrDigitTimeRange {
+			t.Errorf("%v : expected %v got %v", d, ErrDigitTimeRange, err)
+		}
+	}
+
+	r, err := NewDigitReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Header(), h) {
+		t.Errorf("Expected header %v got %v", h, r.Header())
+	}
+	for _, d := range digits {
+		got, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, d) {
+			t.Errorf("Expected %v got %v", d, got)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected EOF got %v", err)
+	}
+
+	r, _ = NewDigitReader(bytes.NewReader(buf.Bytes()[:buf.Len()-2]))
+	r.Read()
+	r.Read()
+	if _, err := r.Read(); err != ErrInvalidDigit {
+		t.Errorf("Expected %v for a truncated file got %v", ErrInvalidDigit, err)
+	}
+	if _, err := NewDigitReader(bytes.NewReader([]byte("not a digit file"))); err != ErrNotDigitFile {
+		t.Errorf("Expected %v got %v", ErrNotDigitFile, err)
+	}
+}
+
+func TestWritePacket(t *testing.T) {
+	p, err := NewPacket(DataTruncatedPKT, 3, 1, 2, 100, []Cluster{NewCluster(5, []int{1, 2}), NewCluster(9, []int{3})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, _ := NewDigitWriter(&buf, DigitHeader{})
+	if err := w.WritePacket(p); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if w.NofDigits() != 2 {
+		t.Errorf("Expected 2 digits got %d", w.NofDigits())
+	}
+	r, _ := NewDigitReader(&buf)
+	r.Read()
+	d, _ := r.Read()
+	expected := Digit{ELink: 3, Hadd: 1, CHadd: 2, BX: 100, Timestamp: 9, Samples: []int{3}, Flags: FlagTruncated}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Expected %v got %v", expected, d)
+	}
+}
@@ -1,12 +1,25 @@
 package sampa
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 
 	"github.com/mrrtf/sampa/pkg/bitset"
 )
 
+var (
+	ErrCorruptedHeader = errors.New("sampa: header with an uncorrectable error")
+	ErrInvalidCluster  = errors.New("sampa: cluster size exceeds the packet payload")
+	ErrTwoPackets      = errors.New("sampa: two packets completed by the same two bits")
+	ErrDataParity      = errors.New("sampa: wrong parity of the packet payload")
+)
+
+// maxSyncSearch is the number of bits an elink accumulates while
+// looking for a sync before it drops the oldest ones
+const maxSyncSearch = 1024
+
 // elink is a bitset with some helper methods to
 // split it into 10-bits ints
 type elink struct {
@@ -16,6 +29,11 @@ type elink struct {
 	nsync      int
 	sdh        SampaDataHeader
 	id         int
+	ncorrected int
+	corrected  bool      // whether the current header was corrected
+	check      bool      // whether to check (and correct) the data headers
+	nbits      int       // number of bits received
+	trace      io.Writer // where to trace the state transitions, if not nil
 }
 
 func NewELink(id int) *elink {
@@ -38,51 +56,53 @@ func (p *elink) AppendBit(bit bool) (*Packet, error) {
 	if err != nil {
 		return nil, err
 	}
+	p.nbits++
 	if p.Length() != p.checkpoint {
 		return nil, nil
 	}
-	return p.Process(), nil
+	return p.Process()
 }
 
 // Append adds two bits at the end of the bitset.
+// Both bits are always appended, even if the first
+// one leads to an error.
 func (p *elink) Append(bit0, bit1 bool) (*Packet, error) {
-	packet0, err := p.AppendBit(bit0)
-	if err != nil {
-		return nil, err
+	packet0, err0 := p.AppendBit(bit0)
+	packet1, err1 := p.AppendBit(bit1)
+	err := err0
+	if err == nil {
+		err = err1
 	}
-	packet1, err := p.AppendBit(bit1)
-	if err != nil {
-		return nil, err
-	}
-
 	if packet1 != nil && packet0 != nil {
-		log.Fatal("only one of the two AppendBit method should have returned a packet!!!")
+		return packet0, ErrTwoPackets
 	}
 	if packet1 != nil {
-		return packet1, nil
+		return packet1, err
 	}
-	return packet0, nil
+	return packet0, err
 }
 
 // findSync tries to find a sync word in the last 50
 // bits of the current elink bitset.
 func (p *elink) findSync() {
-	if p.nsync != 0 {
-		panic("wrong logic 2")
-	}
-	if p.indata == true {
-		panic("wrong logic 3")
-	}
-
 	sdh := SampaDataHeader{BitSet: *(p.BitSet.Last(HeaderSize))}
 
 	if !sdh.IsEqual(SyncPattern.BitSet) {
+		if p.Length() >= maxSyncSearch {
+			// only the last bits can be the start of a sync
+			p.Clear()
+			for i := 0; i < HeaderSize-1; i++ {
+				p.BitSet.Append(sdh.Get(i + 1))
+			}
+			p.checkpoint = HeaderSize - 1
+			if p.trace != nil {
+				p.traceTransition(stateSyncSearch, "", nil,
+					fmt.Sprintf("no sync within %d bits, all but the last %d bits dropped", maxSyncSearch, HeaderSize-1))
+			}
+		}
 		p.checkpoint++
 		return
 	}
-	if sdh.PKT() != uint8(SyncPKT) {
-		log.Fatal("something's really wrong : a sync packet MUST have the correct packet type !")
-	}
 
 	log.Println("findSync: found sync #", p.nsync, " for elink #", p.id)
 	p.Clear()
@@ -94,67 +114,124 @@ func (p *elink) findSync() {
 // as either a Sampa header or Sampa data
 // If it's neither, then set the checkpoint at
 // the current length + 2 bits
-func (p *elink) Process() *Packet {
-	if p.Length() != p.checkpoint {
-		panic("wrong logic somewhere")
+//
+// A header with an uncorrectable error makes the elink
+// look for a sync again, as the packet boundaries are lost.
+func (p *elink) Process() (*Packet, error) {
+	from := p.state()
+	var bits string
+	if p.trace != nil && !p.indata {
+		bits = p.BitSet.Last(HeaderSize).String()
 	}
 
 	// first things first : we must find the sync pattern, otherwise
 	// just continue
 	if p.nsync == 0 {
 		p.findSync()
-		return nil
+		if p.trace != nil && p.nsync > 0 {
+			p.traceTransition(from, bits, nil, "sync found")
+		}
+		return nil, nil
 	}
 
 	if p.indata {
 		// data mode, just decode ourselves into
 		// a set of sampa packets
-		packet := p.GetPacket()
+		packet, err := p.GetPacket()
+		packet.err = err
 		p.Clear()
 		p.checkpoint = HeaderSize
 		p.indata = false
-		return &packet
+		if p.trace != nil {
+			reason := fmt.Sprintf("packet of %d clusters", len(packet.clusters))
+			if err != nil {
+				reason = err.Error()
+			}
+			p.traceTransition(from, "", &p.sdh, reason)
+		}
+		return &packet, err
 	}
 
 	// looking for a header
-	if p.checkpoint != HeaderSize {
-		panic(fmt.Sprintf("wrong logic 5 checkpoint %d HeaderSize %d", p.checkpoint, HeaderSize))
-	}
-
 	p.sdh = SampaDataHeader{BitSet: *(p.BitSet.Last(HeaderSize))}
-	// fmt.Println("ELink ", p.id, " ", p.sdh.StringAnnotated("-"))
+	var corrected bool
+	var err error
+	if p.check {
+		corrected, err = CorrectHeader(&p.sdh)
+	}
+	if err != nil {
+		p.nsync = 0
+		p.Clear()
+		p.checkpoint = HeaderSize
+		if p.trace != nil {
+			p.traceTransition(from, bits, &p.sdh, err.Error())
+		}
+		return nil, err
+	}
+	var reason string
+	p.corrected = corrected
+	if corrected {
+		p.ncorrected++
+		reason = "single bit error corrected, "
+	}
 	switch uint(p.sdh.PKT()) {
 	case DataTruncatedPKT, DataTruncatedTriggerTooEarlyPKT, DataTriggerTooEarlyPKT, DataTriggerTooEarlyNumWordsPKT:
 		// data with a problem is still data, i.e. there will
 		// probably be some data words to read in
 		fallthrough
 	case DataPKT:
-		// log.Println("ELink", p.id, "DATA", p.sdh.StringAnnotated(" "))
-		// log.Println(p)
 		dataToGo := p.sdh.NumWords()
 		p.Clear()
+		if dataToGo == 0 {
+			if p.trace != nil {
+				p.traceTransition(from, bits, &p.sdh, reason+"empty data packet")
+			}
+			return &Packet{sdh: p.sdh, elink: p.id, corrected: p.corrected}, nil
+		}
 		p.checkpoint = int(dataToGo * 10)
 		p.indata = true
-		return nil
+		if p.trace != nil {
+			p.traceTransition(from, bits, &p.sdh, reason+fmt.Sprintf("data packet of %d words", dataToGo))
+		}
+		return nil, nil
 	case SyncPKT:
 		p.nsync++
 		p.Clear()
 		p.checkpoint = HeaderSize
-		return nil
+		reason += "sync packet"
 	case HeartBeatPKT:
 		log.Printf("ELink #%d : HEARTBEAT found. Should be do sth about it  ?\n", p.id)
 		log.Println(p)
 		p.Clear()
 		p.checkpoint = HeaderSize
-		return nil
+		reason += "heartbeat packet"
 	default:
 		log.Printf("ELink %d Got a PKT=%d\n", p.id, p.sdh.PKT())
 		log.Println(p)
 		p.Clear()
 		p.checkpoint = HeaderSize
-		return nil
+		reason += fmt.Sprintf("unknown packet type %d", p.sdh.PKT())
 	}
-	return nil
+	if p.trace != nil {
+		p.traceTransition(from, bits, &p.sdh, reason)
+	}
+	return nil, nil
+}
+
+// CheckHeaders enables (or disables) the check of the Hamming code and
+// parity of the data headers, the headers with a single bit error being
+// corrected and the others dropped, and of the parity of the payloads.
+// The check is off by default : the Hamming code computation has only
+// been validated against the sync header and the headers of Encoder,
+// not yet against non-sync headers captured from real SAMPA chips.
+func (p *elink) CheckHeaders(check bool) {
+	p.check = check
+}
+
+// NofCorrectedHeaders returns the number of headers with
+// a single bit error that were corrected
+func (p *elink) NofCorrectedHeaders() int {
+	return p.ncorrected
 }
 
 // Split splits the elink bitset into a slice of 10-bits integers
@@ -176,30 +253,57 @@ func (p *elink) ForceClear() {
 	if p.indata {
 		return
 	}
+	from := p.state()
 	p.nsync = 0
 	p.Clear()
 	p.indata = false
+	if p.trace != nil {
+		p.traceTransition(from, "", nil, "forced clear")
+	}
 }
 
-// Decode returns a SAMPA Packet
-func (p *elink) GetPacket() Packet {
-	// log.Printf("ELink %d PKT %d GetPacket\n", p.id, p.sdh.PKT())
-	// log.Println(p.sdh.StringAnnotated(" "))
+// Reset makes the elink look for a sync again, dropping the bits of
+// the header or payload being read, if any. Unlike ForceClear it also
+// resets an elink in data mode, e.g. when its bit stream is known to
+// be broken.
+func (p *elink) Reset() {
+	from := p.state()
+	p.nsync = 0
+	p.Clear()
+	p.checkpoint = HeaderSize
+	p.indata = false
+	if p.trace != nil {
+		p.traceTransition(from, "", nil, "reset")
+	}
+}
+
+// GetPacket returns the SAMPA Packet made of the current header
+// and payload. ErrInvalidCluster is returned, with the clusters
+// decoded so far, if the payload is not a sequence of clusters,
+// and ErrDataParity, with the whole packet, if the headers are checked
+// and the payload does not match its parity bit.
+func (p *elink) GetPacket() (Packet, error) {
 	tb := p.Split()
 	i := 0
-	packet := Packet{sdh: p.sdh, elink: p.id}
+	packet := Packet{sdh: p.sdh, elink: p.id, corrected: p.corrected}
 	// if data is truncated, do not even try to add anything
 	// to the packet
 	if uint(p.sdh.PKT()) != DataPKT {
-		return packet
+		return packet, nil
 	}
 	for i < len(tb) {
+		if i+2 > len(tb) || i+2+tb[i] > len(tb) {
+			return packet, ErrInvalidCluster
+		}
 		nwords := tb[i]
 		timestamp := tb[i+1]
 		packet.AddCluster(timestamp, tb[i+2:i+2+nwords])
 		i += nwords + 2
 	}
-	return packet
+	if p.check && payloadParity(tb) != p.sdh.DP() {
+		return packet, ErrDataParity
+	}
+	return packet, nil
 
 }
 
@@ -0,0 +1,224 @@
+package sampa
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mrrtf/sampa/pkg/bitset"
+)
+
+var (
+	ErrInvalidELink = errors.New("sampa: invalid elink")
+	ErrTooManyWords = errors.New("sampa: too many 10 bits words for one packet")
+)
+
+// HeartBeatCHadd is the channel address of the heartbeat packets
+const HeartBeatCHadd uint = 0x15
+
+// payloadParity returns the parity of the 10 bits words
+func payloadParity(words []int) bool {
+	p := false
+	for _, w := range words {
+		for k := uint(0); k < 10; k++ {
+			if (w>>k)&1 != 0 {
+				p = !p
+			}
+		}
+	}
+	return p
+}
+
+// NewHeader returns a header of the given packet type for a payload
+// made of the given 10 bits words, with correct parities and Hamming code
+func NewHeader(pkt, hadd, chadd uint, bx uint32, payload []int) (SampaDataHeader, error) {
+	sdh := SampaDataHeader{*bitset.New(HeaderSize)}
+	sdh.SetHamming(0)
+	sdh.SetP(false)
+	if err := sdh.SetPKT(pkt); err != nil {
+		return sdh, err
+	}
+	if err := sdh.SetNumWords(uint(len(payload))); err != nil {
+		return sdh, ErrTooManyWords
+	}
+	if err := sdh.SetHadd(hadd); err != nil {
+		return sdh, err
+	}
+	if err := sdh.SetCHadd(chadd); err != nil {
+		return sdh, err
+	}
+	if err := sdh.SetBXcount(uint(bx)); err != nil {
+		return sdh, err
+	}
+	sdh.SetDP(payloadParity(payload))
+	sdh.SetHamming(uint(ComputeHamming(&sdh)))
+	sdh.SetP(ComputeParity(&sdh))
+	return sdh, nil
+}
+
+// NewCluster returns a cluster of samples starting at timestamp ts
+func NewCluster(ts int, samples []int) Cluster {
+	return Cluster{ts: ts, samples: samples}
+}
+
+// NewDataPacket returns the data packet of channel chadd of chip hadd
+// holding the given clusters, to be sent on the given elink
+func NewDataPacket(elink int, hadd, chadd uint, bx uint32, clusters []Cluster) (*Packet, error) {
+	return NewPacket(DataPKT, elink, hadd, chadd, bx, clusters)
+}
+
+// NewPacket returns a packet of any type holding the given clusters
+func NewPacket(pkt uint, elink int, hadd, chadd uint, bx uint32, clusters []Cluster) (*Packet, error) {
+	sdh, err := NewHeader(pkt, hadd, chadd, bx, clusterWords(clusters))
+	if err != nil {
+		return nil, err
+	}
+	return &Packet{sdh: sdh, clusters: clusters, elink: elink}, nil
+}
+
+// NewHeartBeatPacket returns the heartbeat packet of chip hadd
+func NewHeartBeatPacket(elink int, hadd uint, bx uint32) (*Packet, error) {
+	sdh, err := NewHeader(HeartBeatPKT, hadd, HeartBeatCHadd, bx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Packet{sdh: sdh, elink: elink}, nil
+}
+
+// clusterWords returns the 10 bits words of the payload of the clusters :
+// for each cluster, the number of samples, the timestamp and the samples
+func clusterWords(clusters []Cluster) []int {
+	var words []int
+	for _, c := range clusters {
+		words = append(words, len(c.samples), c.ts)
+		words = append(words, c.samples...)
+	}
+	return words
+}
+
+// Bits returns the serial bit stream of the packet, in time order
+func (p *Packet) Bits() []bool {
+	bits := make([]bool, 0, HeaderSize)
+	for i := 0; i < HeaderSize; i++ {
+		bits = append(bits, p.sdh.Get(i))
+	}
+	for _, w := range clusterWords(p.clusters) {
+		for k := uint(0); k < 10; k++ {
+			bits = append(bits, (w>>k)&1 != 0)
+		}
+	}
+	return bits
+}
+
+// Encoder builds the serial bit streams of the elinks of a GBT link,
+// and multiplexes them into GBT words.
+//
+// The elinks that have nothing to send emit sync packets, so the
+// streams are continuous from one call of GBTWords to the next.
+type Encoder struct {
+	streams [][]bool
+	syncs   []bool
+}
+
+// NewEncoder returns an encoder of the 40 elinks of a GBT link
+func NewEncoder() *Encoder {
+	e := &Encoder{streams: make([][]bool, NofELinks)}
+	for i := 0; i < HeaderSize; i++ {
+		e.syncs = append(e.syncs, SyncPattern.Get(i))
+	}
+	return e
+}
+
+// Sync adds a sync packet to the stream of the given elink
+func (e *Encoder) Sync(elink int) error {
+	if elink < 0 || elink >= len(e.streams) {
+		return ErrInvalidELink
+	}
+	e.streams[elink] = append(e.streams[elink], e.syncs...)
+	return nil
+}
+
+// SyncAll adds a sync packet to the streams of all the elinks
+func (e *Encoder) SyncAll() {
+	for i := range e.streams {
+		e.Sync(i)
+	}
+}
+
+// Add adds the packet to the stream of its elink
+func (e *Encoder) Add(p *Packet) error {
+	if p.elink < 0 || p.elink >= len(e.streams) {
+		return ErrInvalidELink
+	}
+	e.streams[p.elink] = append(e.streams[p.elink], p.Bits()...)
+	return nil
+}
+
+// AddBits adds raw bits to the stream of the given elink
+func (e *Encoder) AddBits(elink int, bits []bool) error {
+	if elink < 0 || elink >= len(e.streams) {
+		return ErrInvalidELink
+	}
+	e.streams[elink] = append(e.streams[elink], bits...)
+	return nil
+}
+
+// Stream returns the bits of the given elink not yet multiplexed
+func (e *Encoder) Stream(elink int) []bool {
+	return e.streams[elink]
+}
+
+// Len returns the number of GBT words needed to send all the
+// pending bits
+func (e *Encoder) Len() int {
+	n := 0
+	for _, s := range e.streams {
+		if len(s) > n {
+			n = len(s)
+		}
+	}
+	return (n + int(nBitsPerChannel) - 1) / int(nBitsPerChannel)
+}
+
+// GBTWords multiplexes the pending bits of all the elinks into GBT words.
+// The shorter streams are completed with sync packets, whose bits
+// exceeding the last GBT word are kept for the next call.
+func (e *Encoder) GBTWords() [][]byte {
+	n := e.Len()
+	words := make([][]byte, n)
+	for i := range e.streams {
+		for len(e.streams[i]) < 2*n {
+			e.streams[i] = append(e.streams[i], e.syncs...)
+		}
+	}
+	for w := 0; w < n; w++ {
+		words[w] = make([]byte, nBytesPerGBT)
+		Multiplex(e.streams, 2*w, words[w])
+	}
+	for i := range e.streams {
+		e.streams[i] = append(e.streams[i][:0], e.streams[i][2*n:]...)
+	}
+	return words
+}
+
+// Multiplex fills the GBT word with the bits pos and pos+1 of each
+// elink stream, i.e. does the inverse of Dispatch.
+// All the streams must hold at least pos+2 bits.
+func Multiplex(streams [][]bool, pos int, word []byte) {
+	for i := range word {
+		word[i] = 0
+	}
+	for elink, s := range streams {
+		i := elink / 4
+		j := uint(elink%4) * nBitsPerChannel
+		if s[pos] {
+			word[i] |= 1 << (j + 1)
+		}
+		if s[pos+1] {
+			word[i] |= 1 << j
+		}
+	}
+}
+
+func (e *Encoder) String() string {
+	return fmt.Sprintf("Encoder %d elinks %d GBT words pending", len(e.streams), e.Len())
+}
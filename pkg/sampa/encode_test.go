@@ -0,0 +1,161 @@
+package sampa
+
+import "testing"
+
+func TestSyncHeader(t *testing.T) {
+	sdh, err := NewHeader(SyncPKT, 0xF, 0, 0xAAAAA, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sdh.IsEqual(SyncPattern.BitSet) {
+		t.Errorf("Expected %X got %X", SyncPattern.Uint64(0, -1), sdh.Uint64(0, -1))
+	}
+	if ComputeHamming(&SyncPattern) != SyncPattern.Hamming() {
+		t.Errorf("Expected hamming %X got %X", SyncPattern.Hamming(), ComputeHamming(&SyncPattern))
+	}
+}
+
+func TestMultiplex(t *testing.T) {
+	e := NewEncoder()
+	e.SyncAll()
+	p, err := NewDataPacket(13, 2, 5, 1234, []Cluster{NewCluster(7, []int{1, 2, 3})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	words := e.GBTWords()
+	if len(words) != (2*HeaderSize+50)/2 {
+		t.Errorf("Expected %d GBT words got %d", (2*HeaderSize+50)/2, len(words))
+	}
+	elinks := make([]ELink, NofELinks)
+	for i := range elinks {
+		elinks[i] = NewELink(i)
+	}
+	var packets []*Packet
+	for _, w := range words {
+		err := DispatchFunc(w, elinks, 0, func(p *Packet) { packets = append(packets, p) })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(packets) != 1 {
+		t.Fatalf("Expected 1 packet got %d", len(packets))
+	}
+	if packets[0].ELink() != 13 || packets[0].String() != p.String() {
+		t.Errorf("Expected %s got %s", p, packets[0])
+	}
+	if packets[0].Header().Hamming() != ComputeHamming(packets[0].Header()) {
+		t.Errorf("Wrong hamming code in %s", packets[0].Header().StringAnnotated(" "))
+	}
+}
+
+// TestSyncHeaderIsValid checks the Hamming code and parity against the
+// sync header, the only header of a real SAMPA chip available here
+// (see CheckHeaders)
+func TestSyncHeaderIsValid(t *testing.T) {
+	sdh := SampaDataHeader{BitSet: *(SyncPattern.BitSet.Last(HeaderSize))}
+	if corrected, err := CorrectHeader(&sdh); corrected || err != nil {
+		t.Errorf("Expected a valid sync header got %v %v", corrected, err)
+	}
+}
+
+func TestCorrectHeader(t *testing.T) {
+	ref, err := NewHeader(DataPKT, 5, 17, 123456, []int{3, 100, 1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < HeaderSize; i++ {
+		sdh, _ := NewHeader(DataPKT, 5, 17, 123456, []int{3, 100, 1, 2, 3})
+		sdh.Set(i, !sdh.Get(i))
+		corrected, err := CorrectHeader(&sdh)
+		if err != nil || !corrected || !sdh.IsEqual(ref.BitSet) {
+			t.Errorf("bit %d : expected header to be corrected got %v %v", i, corrected, err)
+		}
+		sdh.Set(i, !sdh.Get(i))
+		sdh.Set((i+7)%HeaderSize, !sdh.Get((i+7)%HeaderSize))
+		if _, err := CorrectHeader(&sdh); err != ErrCorruptedHeader {
+			t.Errorf("bits %d and %d : expected %v got %v", i, (i+7)%HeaderSize, ErrCorruptedHeader, err)
+		}
+	}
+	if corrected, err := CorrectHeader(&ref); corrected || err != nil {
+		t.Errorf("Expected a valid header got %v %v", corrected, err)
+	}
+}
+
+func TestPacketStatus(t *testing.T) {
+	p, err := NewDataPacket(3, 1, 2, 100, []Cluster{NewCluster(7, []int{1, 2, 3})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEncoder()
+	e.Sync(3)
+	bits := p.Bits()
+	bits[20] = !bits[20]
+	bits[HeaderSize+25] = !bits[HeaderSize+25]
+	e.AddBits(3, bits)
+
+	l := NewELink(3)
+	l.CheckHeaders(true)
+	var packets []*Packet
+	stream := e.Stream(3)
+	for i := 0; i+1 < len(stream); i += 2 {
+		if q, _ := l.Append(stream[i], stream[i+1]); q != nil {
+			packets = append(packets, q)
+		}
+	}
+	if len(packets) != 1 {
+		t.Fatalf("Expected 1 packet got %d", len(packets))
+	}
+	if !packets[0].Corrected() || packets[0].Err() != ErrDataParity {
+		t.Errorf("Expected a corrected header and %v got %v %v", ErrDataParity, packets[0].Corrected(), packets[0].Err())
+	}
+
+	e = NewEncoder()
+	e.Sync(3)
+	bits = p.Bits()
+	bits[HeaderSize+25] = !bits[HeaderSize+25]
+	e.AddBits(3, bits)
+	l = NewELink(3)
+	packets = nil
+	stream = e.Stream(3)
+	for i := 0; i+1 < len(stream); i += 2 {
+		if q, _ := l.Append(stream[i], stream[i+1]); q != nil {
+			packets = append(packets, q)
+		}
+	}
+	if len(packets) != 1 || packets[0].Err() != nil {
+		t.Errorf("Expected 1 packet without payload parity check got %v", packets)
+	}
+}
+
+func TestReset(t *testing.T) {
+	p, err := NewDataPacket(3, 1, 2, 100, []Cluster{NewCluster(7, []int{1, 2, 3, 4, 5, 6})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEncoder()
+	e.Sync(3)
+	e.AddBits(3, p.Bits()[:HeaderSize+20])
+	l := NewELink(3)
+	stream := e.Stream(3)
+	for i := 0; i+1 < len(stream); i += 2 {
+		l.Append(stream[i], stream[i+1])
+	}
+	l.Reset()
+
+	e = NewEncoder()
+	e.Sync(3)
+	e.Add(p)
+	var packets []*Packet
+	stream = e.Stream(3)
+	for i := 0; i+1 < len(stream); i += 2 {
+		if q, _ := l.Append(stream[i], stream[i+1]); q != nil {
+			packets = append(packets, q)
+		}
+	}
+	if len(packets) != 1 || packets[0].String() != p.String() {
+		t.Errorf("Expected the packet after the reset got %v", packets)
+	}
+}
@@ -0,0 +1,231 @@
+package sampa_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+	"github.com/mrrtf/sampa/pkg/sim"
+)
+
+var update = flag.Bool("update", false, "regenerate the testdata DATE and golden files")
+
+// goldenCase is a DATE file of testdata, decoded with an elink mask
+type goldenCase struct {
+	name  string // name of the golden file
+	data  string // name of the DATE file
+	mask  uint64
+	write func(t *testing.T, filename string) []sim.Event
+}
+
+var goldenCases = []goldenCase{
+	{"sync", "sync", 0, writeSync},
+	{"data", "data", 0, writeData},
+	{"data_masked", "data", 0x00000000F0F0000F, writeData},
+	{"heartbeat", "heartbeat", 0, writeHeartBeat},
+	{"truncated", "truncated", 0, writeTruncated},
+}
+
+func packet(t *testing.T, pkt uint, elink int, hadd, chadd uint, bx uint32, clusters ...sampa.Cluster) *sampa.Packet {
+	p, err := sampa.NewPacket(pkt, elink, hadd, chadd, bx, clusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func event(id uint32, packets ...*sampa.Packet) sim.Event {
+	return sim.Event{Header: date.EventHeaderType{EventType: date.PhysicsEvent,
+		EventID: date.NewEventID(1, id, 0)}, Packets: packets}
+}
+
+// dataEvent returns an event with one data packet on every step-th elink
+func dataEvent(t *testing.T, id uint32, step int) sim.Event {
+	e := event(id)
+	for elink := int(id) % step; elink < sampa.NofELinks; elink += step {
+		c1 := sampa.NewCluster(int(id)+elink, []int{elink, 1023 - elink, 512})
+		c2 := sampa.NewCluster(200+elink, []int{int(id)})
+		e.Packets = append(e.Packets, packet(t, sampa.DataPKT, elink, uint(elink/4), uint(elink%32), id*100, c1, c2))
+	}
+	return e
+}
+
+func writeEvents(t *testing.T, s *sim.Simulator, filename string, events []sim.Event) []sim.Event {
+	var buf bytes.Buffer
+	if err := s.WriteDate(&buf, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// writeSync starts each elink with a different number of noise bits
+// before its first sync
+func writeSync(t *testing.T, filename string) []sim.Event {
+	s := sim.NewUnsynced()
+	enc := s.Encoder()
+	for elink := 0; elink < sampa.NofELinks; elink++ {
+		noise := make([]bool, 2*(elink%7)+10*(elink%3))
+		for i := range noise {
+			noise[i] = i%3 != 1
+		}
+		enc.AddBits(elink, noise)
+	}
+	enc.SyncAll()
+	return writeEvents(t, s, filename, []sim.Event{dataEvent(t, 1, 3), dataEvent(t, 2, 5)})
+}
+
+func writeData(t *testing.T, filename string) []sim.Event {
+	return writeEvents(t, sim.New(), filename, []sim.Event{dataEvent(t, 1, 1), dataEvent(t, 2, 3), dataEvent(t, 3, 7)})
+}
+
+func writeHeartBeat(t *testing.T, filename string) []sim.Event {
+	e := event(1)
+	for elink := 0; elink < sampa.NofELinks; elink += 2 {
+		hb, err := sampa.NewHeartBeatPacket(elink, uint(elink/4), 4000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Packets = append(e.Packets, hb,
+			packet(t, sampa.DataPKT, elink, uint(elink/4), 3, 4001, sampa.NewCluster(5, []int{elink, 1, 2})))
+	}
+	return writeEvents(t, sim.New(), filename, []sim.Event{e, dataEvent(t, 2, 4)})
+}
+
+func writeTruncated(t *testing.T, filename string) []sim.Event {
+	e := event(1)
+	for elink := 0; elink < sampa.NofELinks; elink += 3 {
+		e.Packets = append(e.Packets,
+			packet(t, sampa.DataTruncatedPKT, elink, 1, 2, 10, sampa.NewCluster(1, []int{1, 2, 3, 4})),
+			packet(t, sampa.DataPKT, elink, 1, 2, 11, sampa.NewCluster(2, []int{elink})))
+	}
+	return writeEvents(t, sim.New(), filename, []sim.Event{e})
+}
+
+// dump returns the description of a packet
+func dump(elink int, h *sampa.SampaDataHeader, clusters []sampa.Cluster) string {
+	s := fmt.Sprintf("elink %2d pkt %d hadd %2d chadd %2d bx %6d nwords %3d",
+		elink, h.PKT(), h.Hadd(), h.CHadd(), h.BXcount(), h.NumWords())
+	for _, c := range clusters {
+		s += " | " + strings.TrimSpace(c.String())
+	}
+	return s
+}
+
+// expected returns the golden output of the events, i.e. the packets
+// the decoder should find, ordered by elink
+func expected(events []sim.Event, mask uint64) []string {
+	var lines []string
+	var elinks []int
+	for _, e := range events {
+		for _, p := range e.Packets {
+			if mask&(uint64(1)<<uint(p.ELink())) != 0 || uint(p.Header().PKT()) == sampa.HeartBeatPKT {
+				continue
+			}
+			clusters := p.Clusters()
+			if uint(p.Header().PKT()) != sampa.DataPKT {
+				// the payload of the packets with problems is not decoded
+				clusters = nil
+			}
+			lines = append(lines, dump(p.ELink(), p.Header(), clusters))
+			elinks = append(elinks, p.ELink())
+		}
+	}
+	sortByELink(lines, elinks)
+	return lines
+}
+
+// decode decodes the DATE file through the date, Dispatch and elink chain
+func decode(t *testing.T, filename string, mask uint64) []string {
+	r := date.NewReader(filename)
+	if r == nil {
+		t.Fatalf("cannot open %s", filename)
+	}
+	defer r.Close()
+	var elinks []sampa.ELink
+	for i := 0; i < sampa.NofELinks; i++ {
+		elinks = append(elinks, sampa.NewELink(i))
+	}
+	var lines []string
+	var ids []int
+	ten := make([]byte, 10)
+	for {
+		_, err := r.Read(ten)
+		if err == io.EOF {
+			break
+		}
+		if date.IsEndOfEvent(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sampa.DispatchFunc(ten, elinks, mask, func(p *sampa.Packet) {
+			lines = append(lines, dump(p.ELink(), p.Header(), p.Clusters()))
+			ids = append(ids, p.ELink())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sortByELink(lines, ids)
+	return lines
+}
+
+type byELink struct {
+	lines  []string
+	elinks []int
+}
+
+func (b byELink) Len() int           { return len(b.lines) }
+func (b byELink) Less(i, j int) bool { return b.elinks[i] < b.elinks[j] }
+func (b byELink) Swap(i, j int) {
+	b.lines[i], b.lines[j] = b.lines[j], b.lines[i]
+	b.elinks[i], b.elinks[j] = b.elinks[j], b.elinks[i]
+}
+
+// sortByELink sorts the lines by elink, keeping the time
+// order of the packets of each elink
+func sortByELink(lines []string, elinks []int) {
+	sort.Stable(byELink{lines, elinks})
+}
+
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		dataFile := filepath.Join("testdata", c.data+".date")
+		goldenFile := filepath.Join("testdata", c.name+".golden")
+		if *update {
+			os.MkdirAll("testdata", 0755)
+			events := c.write(t, dataFile)
+			golden := strings.Join(expected(events, c.mask), "\n") + "\n"
+			if err := ioutil.WriteFile(goldenFile, []byte(golden), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := ioutil.ReadFile(goldenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Split(strings.TrimSuffix(string(golden), "\n"), "\n")
+		got := decode(t, dataFile, c.mask)
+		if len(got) != len(want) {
+			t.Errorf("%s : expected %d packets got %d", c.name, len(want), len(got))
+		}
+		for i := 0; i < len(got) && i < len(want); i++ {
+			if got[i] != want[i] {
+				t.Errorf("%s : packet %d\nexpected %s\n     got %s", c.name, i, want[i], got[i])
+			}
+		}
+	}
+}
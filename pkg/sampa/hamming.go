@@ -0,0 +1,75 @@
+package sampa
+
+// ComputeHamming returns the 6 bits Hamming code protecting the
+// header bits 7 to 49 (i.e. all the bits after the parity bit)
+func ComputeHamming(sdh *SampaDataHeader) uint8 {
+	var h uint8
+	pos := uint(1) // position in the code word, parity positions are powers of 2
+	for i := PKTFirstBit; i <= DPBit; i++ {
+		for pos&(pos-1) == 0 {
+			pos++
+		}
+		if sdh.Get(i) {
+			h ^= uint8(pos)
+		}
+		pos++
+	}
+	return h & 0x3F
+}
+
+// ComputeParity returns the parity bit of the header, i.e. the
+// parity of all its other bits (Hamming code included), as found
+// in the sync header
+func ComputeParity(sdh *SampaDataHeader) bool {
+	p := false
+	for i := HammingFirstBit; i <= DPBit; i++ {
+		if i != PBit && sdh.Get(i) {
+			p = !p
+		}
+	}
+	return p
+}
+
+// hammingPositions maps the positions of the Hamming code word
+// to the header bits
+var hammingPositions = func() map[uint]int {
+	m := make(map[uint]int)
+	for i := HammingFirstBit; i <= HammingLastBit; i++ {
+		m[uint(1)<<uint(i-HammingFirstBit)] = i
+	}
+	pos := uint(1)
+	for i := PKTFirstBit; i <= DPBit; i++ {
+		for pos&(pos-1) == 0 {
+			pos++
+		}
+		m[pos] = i
+		pos++
+	}
+	return m
+}()
+
+// CorrectHeader checks the Hamming code and parity of the header
+// and corrects it if it has a single bit error, in which case
+// corrected is true. ErrCorruptedHeader is returned if the
+// header has more errors.
+func CorrectHeader(sdh *SampaDataHeader) (corrected bool, err error) {
+	syndrome := uint(ComputeHamming(sdh) ^ sdh.Hamming())
+	parityError := ComputeParity(sdh) != sdh.P()
+	switch {
+	case syndrome == 0 && !parityError:
+		return false, nil
+	case syndrome == 0:
+		// the parity bit itself is wrong
+		sdh.SetP(!sdh.P())
+		return true, nil
+	case !parityError:
+		// double error
+		return false, ErrCorruptedHeader
+	}
+	bit, ok := hammingPositions[syndrome]
+	if !ok {
+		return false, ErrCorruptedHeader
+	}
+	sdh.Set(bit, !sdh.Get(bit))
+	return true, nil
+}
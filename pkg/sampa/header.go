@@ -182,7 +182,7 @@ func init() {
 	if sp != 0x1555540F00113 {
 		log.Fatal(fmt.Sprintf("SyncPattern expected to be 0x1555540F00113 but is %x", sp))
 	}
-	fmt.Printf("SYNC is assumed to be %X = %s ; count=%d\n", SyncPattern.Uint64(0, -1),
+	log.Printf("SYNC is assumed to be %X = %s ; count=%d\n", SyncPattern.Uint64(0, -1),
 		SyncPattern.String(), SyncPattern.Count())
-	fmt.Printf("SYNC R to L : %s\n", SyncPattern.StringLSBRight())
+	log.Printf("SYNC R to L : %s\n", SyncPattern.StringLSBRight())
 }
@@ -7,15 +7,58 @@ import "fmt"
 // Note that we use ints whereas each value really
 // is 10 bits (or 20 bits for samples in sum mode)
 type Packet struct {
-	sdh      SampaDataHeader
-	clusters []Cluster // clusters
-	elink    int
+	sdh       SampaDataHeader
+	clusters  []Cluster // clusters
+	elink     int
+	corrected bool  // the header had a single bit error, corrected
+	err       error // error found while decoding the payload
 }
 
 func (p *Packet) AddCluster(timestamp int, samples []int) {
 	p.clusters = append(p.clusters, Cluster{ts: timestamp, samples: samples})
 }
 
+// ELink returns the elink the packet was (or is to be) sent on
+func (p *Packet) ELink() int {
+	return p.elink
+}
+
+// Header returns the SAMPA header of the packet
+func (p *Packet) Header() *SampaDataHeader {
+	return &p.sdh
+}
+
+// Corrected tells whether the header of the packet had a single
+// bit error which was corrected (see CheckHeaders)
+func (p *Packet) Corrected() bool {
+	return p.corrected
+}
+
+// Err returns the error found while decoding the payload of
+// the packet (ErrInvalidCluster or ErrDataParity), if any
+func (p *Packet) Err() error {
+	return p.err
+}
+
+// Clusters returns the clusters of the packet
+func (p *Packet) Clusters() []Cluster {
+	return p.clusters
+}
+
+// WithClusters returns a copy of the packet holding the given clusters
+// instead of its own, e.g. after some processing of the samples.
+// The header keeps the packet type, addresses and bunch crossing of
+// the packet, its number of words, parities and Hamming code being
+// computed again for the new payload. The decoding status (Corrected
+// and Err) of the packet is kept.
+func (p *Packet) WithClusters(clusters []Cluster) (*Packet, error) {
+	sdh, err := NewHeader(uint(p.sdh.PKT()), uint(p.sdh.Hadd()), uint(p.sdh.CHadd()), p.sdh.BXcount(), clusterWords(clusters))
+	if err != nil {
+		return nil, err
+	}
+	return &Packet{sdh: sdh, clusters: clusters, elink: p.elink, corrected: p.corrected, err: p.err}, nil
+}
+
 func (p *Packet) String() string {
 	v := fmt.Sprintf("ELink %d Packet [%d,%d] ", p.elink, p.sdh.Hadd(), p.sdh.CHadd())
 
@@ -3,11 +3,12 @@ package sampa
 import (
 	"errors"
 	"fmt"
-	"log"
+	"io"
 )
 
 var (
-	ErrIncorrectSize = errors.New("sampa: incorrect GBT size")
+	ErrIncorrectSize   = errors.New("sampa: incorrect GBT size")
+	ErrNotEnoughELinks = errors.New("sampa: a GBT word needs 40 elinks")
 )
 
 type ELink interface {
@@ -18,23 +19,63 @@ type ELink interface {
 	Id() int
 }
 
+// Decoder is an ELink whose header check and tracing can be set,
+// and which can be reset, as the ones of NewELink
+type Decoder interface {
+	ELink
+	CheckHeaders(check bool)
+	NofCorrectedHeaders() int
+	SetTrace(w io.Writer)
+	Reset()
+}
+
+// ELinkError records an error and the elink where it happened
+type ELinkError struct {
+	ELink int
+	Err   error
+}
+
+func (e *ELinkError) Error() string {
+	return fmt.Sprintf("elink %d : %v", e.ELink, e.Err)
+}
+
 const (
 	HeaderSize int = 50
 	// nBitsPerChannel is the number of bits a channel uses in a 80-bits GBT word
 	nBitsPerChannel uint = 2
 	nBytesPerGBT    int  = 10
+	// NofELinks is the number of elinks of a GBT link
+	NofELinks int = 40
 )
 
 // Dispatch splits the 10 bytes composing a 80 bits GBT word
-// into n elink data groups of 80/n bits
+// into n elink data groups of 80/n bits, and prints the
+// packets that are completed.
+//
+// Elink 4*i+k gets bits 2k+1 then 2k of byte i (see Multiplex
+// for the inverse operation)
 func Dispatch(bytes []byte, elinks []ELink, elinkmask uint64) error {
+	return DispatchFunc(bytes, elinks, elinkmask, printPacket)
+}
+
+// DispatchFunc is like Dispatch but hands the completed
+// packets to the handle function.
+//
+// An error of one elink does not stop the dispatching to the
+// others : the first one is returned, as an *ELinkError,
+// once the whole GBT word is dispatched.
+func DispatchFunc(bytes []byte, elinks []ELink, elinkmask uint64, handle func(*Packet)) error {
 	if len(bytes) != nBytesPerGBT {
 		return ErrIncorrectSize
 	}
+	if len(elinks) < NofELinks {
+		return ErrNotEnoughELinks
+	}
 	var elink uint64 = 0
-	for i := 0; i < 1; i++ { //FIXME: 1 should be len(bytes)=10=nBytesPerGBT
+	var firstErr error
+	for i := 0; i < nBytesPerGBT; i++ {
 		b := uint(bytes[i])
-		for j := uint(0); j < 8; j += nBitsPerChannel { //FIXME:should be 8/nBitsPerChannel
+		for j := uint(0); j < 8; j += nBitsPerChannel {
 			ch := elinks[elink]
 			if elinkmask&(uint64(1)<<elink) > 0 {
 				// skip masked-out elinks
@@ -48,13 +89,17 @@ func Dispatch(bytes []byte, elinks []ELink, elinkmask uint64) error {
 			mask /= 2
 			bit1 := (b & mask) > 0
 			packet, err := ch.Append(bit0, bit1)
-			if err != nil {
-				log.Fatalf("Dispatch error : byte %d elink %d", b, i)
+			if err != nil && firstErr == nil {
+				firstErr = &ELinkError{ELink: ch.Id(), Err: err}
 			}
 			if packet != nil {
-				fmt.Println(packet.String())
+				handle(packet)
 			}
 		}
 	}
-	return nil
+	return firstErr
+}
+
+func printPacket(packet *Packet) {
+	fmt.Println(packet.String())
 }
@@ -0,0 +1,47 @@
+package sampa
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// States of the elink decoding, as shown in the traces
+const (
+	stateSyncSearch = "sync search"
+	stateHeader     = "header read"
+	stateData       = "data read"
+)
+
+// SetTrace makes the elink write every transition of its state machine
+// into w, or stops the tracing if w is nil.
+// It is not part of the ELink interface but of Decoder.
+func (p *elink) SetTrace(w io.Writer) {
+	p.trace = w
+}
+
+// state returns the current state of the elink
+func (p *elink) state() string {
+	if p.nsync == 0 {
+		return stateSyncSearch
+	}
+	if p.indata {
+		return stateData
+	}
+	return stateHeader
+}
+
+// traceTransition writes the transition from state from to the current
+// state, with the bits that were examined (if any), the decoded header
+// (if any) and the reason of the transition
+func (p *elink) traceTransition(from string, bits string, sdh *SampaDataHeader, reason string) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "elink %d bit %d : %s -> %s : %s\n", p.id, p.nbits, from, p.state(), reason)
+	if bits != "" {
+		fmt.Fprintf(&buf, "  bits   %s\n", bits)
+	}
+	if sdh != nil {
+		fmt.Fprintf(&buf, "  header %s\n", sdh.StringAnnotated(" | "))
+	}
+	p.trace.Write(buf.Bytes())
+}
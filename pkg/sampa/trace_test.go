@@ -0,0 +1,64 @@
+package sampa
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	e := NewEncoder()
+	e.Sync(3)
+	p, err := NewDataPacket(3, 1, 2, 100, []Cluster{NewCluster(7, []int{1, 2, 3})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Add(p)
+	bad := p.Bits()[:HeaderSize]
+	bad[0], bad[9] = !bad[0], !bad[9]
+	e.AddBits(3, bad)
+
+	var buf bytes.Buffer
+	var l Decoder = NewELink(3)
+	l.CheckHeaders(true)
+	l.SetTrace(&buf)
+	stream := e.Stream(3)
+	for i := 0; i+1 < len(stream); i += 2 {
+		l.Append(stream[i], stream[i+1])
+	}
+
+	var transitions []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "elink") {
+			transitions = append(transitions, line)
+		}
+	}
+	expected := []string{
+		"elink 3 bit 50 : sync search -> header read : sync found",
+		"elink 3 bit 100 : header read -> data read : data packet of 5 words",
+		"elink 3 bit 150 : data read -> header read : packet of 1 clusters",
+		"elink 3 bit 200 : header read -> sync search : " + ErrCorruptedHeader.Error(),
+	}
+	if strings.Join(transitions, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), buf.String())
+	}
+	if !strings.Contains(buf.String(), "  bits   "+SyncPattern.String()) {
+		t.Errorf("Expected the sync bits in the trace")
+	}
+	if !strings.Contains(buf.String(), "  header "+p.Header().StringAnnotated(" | ")) {
+		t.Errorf("Expected the data header in the trace")
+	}
+}
+
+func TestTraceSyncSearchReset(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewELink(0)
+	l.SetTrace(&buf)
+	for i := 0; i < maxSyncSearch/2+10; i++ {
+		l.Append(false, false)
+	}
+	expected := "elink 0 bit 1024 : sync search -> sync search : no sync within 1024 bits, all but the last 49 bits dropped"
+	if strings.TrimSpace(buf.String()) != expected {
+		t.Errorf("Expected %q got %q", expected, buf.String())
+	}
+}
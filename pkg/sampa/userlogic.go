@@ -0,0 +1,97 @@
+package sampa
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUserLogicError   = errors.New("sampa: user logic word with error bits set")
+	ErrInvalidDualSampa = errors.New("sampa: user logic word for an unknown dual sampa")
+)
+
+const (
+	// UserLogicPayloadSize is the number of SAMPA bits in a user logic word
+	UserLogicPayloadSize int = 50
+	// UserLogicPadding is the word used by the CRU to fill up its pages
+	UserLogicPadding uint64 = 0xFEEDDEEDFEEDDEED
+)
+
+// UserLogicWord is a 64 bits word of the CRU user logic format,
+// where the SAMPA bits of one elink are already demultiplexed :
+//
+// 50 bits SAMPA payload, earliest bit first (LSB)
+//
+//	2 bits error
+//	1 bit  incomplete : the word ends a SAMPA packet and only its
+//	       first bits are meaningful
+//	6 bits dual sampa (i.e. elink) id
+//	5 bits GBT link id
+type UserLogicWord uint64
+
+func (w UserLogicWord) Payload() uint64 {
+	return uint64(w) & (uint64(1)<<uint(UserLogicPayloadSize) - 1)
+}
+
+func (w UserLogicWord) Error() uint8 {
+	return uint8(w>>50) & 0x3
+}
+
+func (w UserLogicWord) Incomplete() bool {
+	return (w>>52)&1 != 0
+}
+
+func (w UserLogicWord) DualSampaID() int {
+	return int(w>>53) & 0x3F
+}
+
+func (w UserLogicWord) LinkID() int {
+	return int(w>>59) & 0x1F
+}
+
+func (w UserLogicWord) String() string {
+	return fmt.Sprintf("UL link %d ds %d error %d incomplete %v payload 0x%013X",
+		w.LinkID(), w.DualSampaID(), w.Error(), w.Incomplete(), w.Payload())
+}
+
+// DispatchUserLogic feeds the payload of a user logic word into the
+// elink of its dual sampa, and hands the completed packets to the
+// handle function.
+//
+// Contrary to the GBT mode (see DispatchFunc) there is no bit
+// demultiplexing to do : the elinks receive 50 bits at once.
+// The bits of an incomplete word that follow the end of the packet
+// are ignored. Padding words are ignored, and words with errors are
+// not dispatched.
+func DispatchUserLogic(word uint64, elinks []ELink, elinkmask uint64, handle func(*Packet)) error {
+	if word == UserLogicPadding {
+		return nil
+	}
+	w := UserLogicWord(word)
+	if w.Error() != 0 {
+		return ErrUserLogicError
+	}
+	ds := w.DualSampaID()
+	if ds >= len(elinks) {
+		return ErrInvalidDualSampa
+	}
+	if elinkmask&(uint64(1)<<uint(ds)) > 0 {
+		return nil
+	}
+	payload := w.Payload()
+	for i := uint(0); i < uint(UserLogicPayloadSize); i += nBitsPerChannel {
+		bit0 := (payload>>i)&1 != 0
+		bit1 := (payload>>(i+1))&1 != 0
+		packet, err := elinks[ds].Append(bit0, bit1)
+		if packet != nil {
+			handle(packet)
+		}
+		if err != nil {
+			return &ELinkError{ELink: ds, Err: err}
+		}
+		if packet != nil && w.Incomplete() {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package sampa
+
+import "testing"
+
+// testBits returns the bits (in time order) of a sync packet followed
+// by a data packet with one cluster
+func testBits(hadd, chadd uint, ts int, samples []int) []bool {
+	var bits []bool
+	for i := 0; i < HeaderSize; i++ {
+		bits = append(bits, SyncPattern.Get(i))
+	}
+	words := append([]int{len(samples), ts}, samples...)
+	sdh, _ := NewHeader(DataPKT, hadd, chadd, 0, words)
+	for i := 0; i < HeaderSize; i++ {
+		bits = append(bits, sdh.Get(i))
+	}
+	for _, w := range words {
+		for k := uint(0); k < 10; k++ {
+			bits = append(bits, (w>>k)&1 != 0)
+		}
+	}
+	return bits
+}
+
+func testELinks() []ELink {
+	var elinks []ELink
+	for i := 0; i < 40; i++ {
+		elinks = append(elinks, NewELink(i))
+	}
+	return elinks
+}
+
+// userLogicWords packs the bits of elink ds into user logic words
+func userLogicWords(bits []bool, ds int) []uint64 {
+	var words []uint64
+	for i := 0; i < len(bits); i += UserLogicPayloadSize {
+		w := uint64(ds) << 53
+		for k := 0; k < UserLogicPayloadSize && i+k < len(bits); k++ {
+			if bits[i+k] {
+				w |= uint64(1) << uint(k)
+			}
+		}
+		if i+UserLogicPayloadSize > len(bits) {
+			w |= uint64(1) << 52
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+func TestUserLogicWord(t *testing.T) {
+	w := UserLogicWord(uint64(0x1234567890ABC) | uint64(2)<<50 | uint64(1)<<52 | uint64(37)<<53 | uint64(11)<<59)
+	if w.Payload() != 0x1234567890ABC || w.Error() != 2 || !w.Incomplete() ||
+		w.DualSampaID() != 37 || w.LinkID() != 11 {
+		t.Errorf("Unexpected decoding %v", w)
+	}
+}
+
+func TestUserLogicSameAsGBT(t *testing.T) {
+	samples := []int{10, 20, 1023, 0, 512, 7}
+	bits := testBits(3, 17, 42, samples)
+
+	var gbtPackets []string
+	elinks := testELinks()
+	gbt := make([]byte, nBytesPerGBT)
+	for i := 0; i < len(bits); i += 2 {
+		// elink 0 is fed by the 2 lowest bits of the first GBT byte
+		gbt[0] = 0
+		if bits[i] {
+			gbt[0] |= 2
+		}
+		if bits[i+1] {
+			gbt[0] |= 1
+		}
+		err := DispatchFunc(gbt, elinks, 0, func(p *Packet) { gbtPackets = append(gbtPackets, p.String()) })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ulPackets []string
+	elinks = testELinks()
+	for _, w := range userLogicWords(bits, 0) {
+		err := DispatchUserLogic(w, elinks, 0, func(p *Packet) { ulPackets = append(ulPackets, p.String()) })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(gbtPackets) != 1 || len(ulPackets) != 1 {
+		t.Fatalf("Expected 1 packet in each mode got %d (GBT) and %d (UL)", len(gbtPackets), len(ulPackets))
+	}
+	if gbtPackets[0] != ulPackets[0] {
+		t.Errorf("Expected identical packets got %s (GBT) and %s (UL)", gbtPackets[0], ulPackets[0])
+	}
+}
+
+func TestUserLogicErrors(t *testing.T) {
+	elinks := testELinks()
+	handle := func(p *Packet) {}
+	if err := DispatchUserLogic(UserLogicPadding, elinks, 0, handle); err != nil {
+		t.Errorf("Expected padding to be ignored got %v", err)
+	}
+	if err := DispatchUserLogic(uint64(1)<<50, elinks, 0, handle); err != ErrUserLogicError {
+		t.Errorf("Expected %v got %v", ErrUserLogicError, err)
+	}
+	if err := DispatchUserLogic(uint64(45)<<53, elinks, 0, handle); err != ErrInvalidDualSampa {
+		t.Errorf("Expected %v got %v", ErrInvalidDualSampa, err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package sim simulates the data of the SAMPA chips of one GBT link,
+// as seen by the readout : SAMPA packets are serialized on their
+// elinks, multiplexed into GBT words, and optionally written
+// into DATE events.
+//
+// The simulated data are the ground truth of the decoder tests.
+package sim
+
+import (
+	"io"
+	"os"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+// Event is the set of packets sent during one (DATE) event
+type Event struct {
+	Header  date.EventHeaderType
+	Packets []*sampa.Packet
+}
+
+// Simulator turns events into GBT words
+type Simulator struct {
+	encoder *sampa.Encoder
+}
+
+// New returns a simulator of one GBT link, whose elinks
+// all start with a sync packet
+func New() *Simulator {
+	s := NewUnsynced()
+	s.encoder.SyncAll()
+	return s
+}
+
+// NewUnsynced returns a simulator of one GBT link whose elinks
+// start empty, e.g. to start them with some noise, see Encoder
+func NewUnsynced() *Simulator {
+	return &Simulator{encoder: sampa.NewEncoder()}
+}
+
+// Encoder returns the encoder of the simulator, for a low level
+// control of the elink streams
+func (s *Simulator) Encoder() *sampa.Encoder {
+	return s.encoder
+}
+
+// Encode returns the GBT words carrying all the packets of the event.
+// The elinks that are done before the others send sync packets.
+func (s *Simulator) Encode(e Event) ([][]byte, error) {
+	for _, p := range e.Packets {
+		if err := s.encoder.Add(p); err != nil {
+			return nil, err
+		}
+	}
+	return s.encoder.GBTWords(), nil
+}
+
+// WriteDate writes each event as a DATE event holding its GBT words
+func (s *Simulator) WriteDate(w io.Writer, events []Event) error {
+	dw := date.NewWriter(w)
+	for _, e := range events {
+		words, err := s.Encode(e)
+		if err != nil {
+			return err
+		}
+		if err := dw.WriteEvent(e.Header, words); err != nil {
+			return err
+		}
+	}
+	return dw.Flush()
+}
+
+// WriteDateFile writes the events into a new DATE file
+func WriteDateFile(filename string, events []Event) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = New().WriteDate(f, events)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package sim
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mrrtf/sampa/pkg/date"
+	"github.com/mrrtf/sampa/pkg/sampa"
+)
+
+func testEvents(t *testing.T) []Event {
+	var events []Event
+	for ev := 0; ev < 3; ev++ {
+		e := Event{Header: date.EventHeaderType{EventType: date.PhysicsEvent,
+			EventID: date.NewEventID(1, uint32(ev), 0)}}
+		for elink := 0; elink < sampa.NofELinks; elink += 7 + ev {
+			clusters := []sampa.Cluster{
+				sampa.NewCluster(10+ev, []int{elink, 2 * elink, 1023}),
+				sampa.NewCluster(100, []int{ev})}
+			p, err := sampa.NewDataPacket(elink, uint(elink%16), uint(ev), uint32(1000*ev), clusters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Packets = append(e.Packets, p)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestRoundTrip(t *testing.T) {
+	f, err := ioutil.TempFile("", "simtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := f.Name()
+	f.Close()
+	defer os.Remove(filename)
+
+	events := testEvents(t)
+	if err := WriteDateFile(filename, events); err != nil {
+		t.Fatal(err)
+	}
+
+	r := date.NewReader(filename)
+	defer r.Close()
+	var elinks []sampa.ELink
+	for i := 0; i < sampa.NofELinks; i++ {
+		elinks = append(elinks, sampa.NewELink(i))
+	}
+	// packetID identifies a packet of the test events,
+	// each elink sending at most one packet per event
+	type packetID struct {
+		event date.EventID
+		elink int
+	}
+	got := make(map[packetID]*sampa.Packet)
+	n := 0
+	ten := make([]byte, 10)
+	for {
+		_, err := r.Read(ten)
+		if err == io.EOF {
+			break
+		}
+		if date.IsEndOfEvent(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := r.Header().EventID
+		err = sampa.DispatchFunc(ten, elinks, 0, func(p *sampa.Packet) {
+			got[packetID{id, p.ELink()}] = p
+			n++
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.NofEvents() != len(events) {
+		t.Errorf("Expected %d events got %d", len(events), r.NofEvents())
+	}
+	nexpected := 0
+	for _, e := range events {
+		for _, p := range e.Packets {
+			nexpected++
+			q := got[packetID{e.Header.EventID, p.ELink()}]
+			if q == nil {
+				t.Errorf("Missing packet %s", p)
+				continue
+			}
+			if !q.Header().IsEqual(p.Header().BitSet) || q.Err() != nil {
+				t.Errorf("Expected header %s got %s (%v)", p.Header().StringAnnotated(" "), q.Header().StringAnnotated(" "), q.Err())
+			}
+			if len(q.Clusters()) != len(p.Clusters()) {
+				t.Errorf("Expected %d clusters got %d", len(p.Clusters()), len(q.Clusters()))
+				continue
+			}
+			for i, c := range p.Clusters() {
+				d := q.Clusters()[i]
+				if d.Timestamp() != c.Timestamp() || !reflect.DeepEqual(d.Samples(), c.Samples()) {
+					t.Errorf("Expected cluster %s got %s", c.String(), d.String())
+				}
+			}
+		}
+	}
+	if n != nexpected {
+		t.Errorf("Expected %d packets got %d", nexpected, n)
+	}
+}